@@ -1,6 +1,7 @@
 package api_test
 
 import (
+	"encoding/hex"
 	"testing"
 
 	"github.com/golang/protobuf/proto"
@@ -22,7 +23,8 @@ func TestMarshalUnmarshalNode(t *testing.T) {
 
 	var node2 api.Node
 	err = proto.Unmarshal(bz, &node2)
-	require.Equal(t, node, &node2)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(node, &node2))
 
 	nodes := &api.Nodes{
 		Nodes: []*api.Node{node, &node2},
@@ -33,7 +35,39 @@ func TestMarshalUnmarshalNode(t *testing.T) {
 
 	var nodes2 api.Nodes
 	err = proto.Unmarshal(bz, &nodes2)
-	require.Equal(t, nodes, &nodes2)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(nodes, &nodes2))
+}
+
+func TestNode_IsOrphaned(t *testing.T) {
+	// as filled in by compact.Annotate: a live write has only FirstVersion, an overwritten or deleted one LastVersion
+	// too, and a delete neither
+	require.False(t, (&api.Node{Block: 3, FirstVersion: 3}).IsOrphaned())
+	require.True(t, (&api.Node{Block: 3, FirstVersion: 3, LastVersion: 5}).IsOrphaned())
+	require.True(t, (&api.Node{Block: 3, FirstVersion: 3, LastVersion: 3}).IsOrphaned())
+	require.False(t, (&api.Node{Block: 5, Delete: true}).IsOrphaned())
+	require.False(t, (&api.Node{Block: 3}).IsOrphaned())
+}
+
+func TestLeafHash(t *testing.T) {
+	node := &api.Node{Key: []byte("key"), Value: []byte("value"), Block: 1}
+	require.Equal(t, "85e286d2d33ee15ccc8a98f26ad8305dac3512dd5658432351c74b93a6471211", hex.EncodeToString(node.LeafHash()))
+
+	addr := make([]byte, 20)
+	for i := range addr {
+		addr[i] = byte(i)
+	}
+	key := append(append([]byte{0x02, 20}, addr...), "uatom"...)
+	require.Equal(t, "823c0bc37f5aebc60513e345a0103ecfe1a07d64a8e2ff90a5313837c35c0c79",
+		hex.EncodeToString(api.LeafHash(key, []byte("1000"), 12345678)))
+
+	require.NoError(t, node.VerifyHash())
+	node.FillHash()
+	require.NoError(t, node.VerifyHash())
+	node.Block = 2
+	require.ErrorIs(t, node.VerifyHash(), api.ErrHashMismatch)
+
+	require.Nil(t, (&api.Node{Key: []byte("key"), Delete: true}).LeafHash())
 }
 
 const testDbPath = "/Users/mattk/src/scratch/cosmosdb"
@@ -70,3 +104,78 @@ const testDbPath = "/Users/mattk/src/scratch/cosmosdb"
 //		i++
 //	}
 //}
+
+func TestParseDenomTrace(t *testing.T) {
+	cases := []struct {
+		denom     string
+		path      string
+		baseDenom string
+	}{
+		{"", "", ""},
+		{"uatom", "", "uatom"},
+		{"uatom/", "", "uatom/"},
+		{"gamm/pool/1", "", "gamm/pool/1"},
+		{"gamm//pool//1", "", "gamm//pool//1"},
+		{"transfer/channel-1/uatom", "transfer/channel-1", "uatom"},
+		{"customtransfer/channel-1/uatom", "customtransfer/channel-1", "uatom"},
+		{"transfer/channel-1/transfer/channel-2/uatom", "transfer/channel-1/transfer/channel-2", "uatom"},
+		{"transfer/channel-1/gamm/pool/1", "transfer/channel-1", "gamm/pool/1"},
+		{"transfer/channel-1/erc20/0x85bcBCd7e79Ec36f4fBBDc54F90C643d921151AA", "transfer/channel-1",
+			"erc20/0x85bcBCd7e79Ec36f4fBBDc54F90C643d921151AA"},
+		{"transfer/channelToA/uatom", "", "transfer/channelToA/uatom"},
+		{"transfer/uatom", "", "transfer/uatom"},
+		{"transfer//uatom", "", "transfer//uatom"},
+		{"channel-1/transfer/uatom", "", "channel-1/transfer/uatom"},
+		{"uatom/transfer", "", "uatom/transfer"},
+		{"transfer/channel-1", "", "transfer/channel-1"},
+		{"transfer/channel-1/", "transfer/channel-1", ""},
+	}
+	for _, tc := range cases {
+		trace := api.ParseDenomTrace(tc.denom)
+		require.Equal(t, tc.path, trace.Path, tc.denom)
+		require.Equal(t, tc.baseDenom, trace.BaseDenom, tc.denom)
+		require.Equal(t, tc.denom, trace.GetFullDenomPath(), tc.denom)
+	}
+}
+
+func TestDenomTrace_IBCDenom(t *testing.T) {
+	trace := api.ParseDenomTrace("transfer/channel-0/uatom")
+	require.Equal(t, "ibc/27394FB092D2ECCD56123C74F36E4C1F926001CEADA9CA97EA622B25F41E5EB2", trace.IBCDenom())
+	require.Equal(t, "27394fb092d2eccd56123c74f36e4c1f926001ceada9ca97ea622b25f41e5eb2", hex.EncodeToString(trace.Hash()))
+
+	trace = api.ParseDenomTrace("transfer/channel-141/uosmo")
+	require.Equal(t, "ibc/14F9BC3E44B8A9C1BE1FB08980FAB87034C9905EF17CF2F5008FC085218811CC", trace.IBCDenom())
+
+	require.Equal(t, "uatom", api.ParseDenomTrace("uatom").IBCDenom())
+}
+
+func TestDenomTrace_Validate(t *testing.T) {
+	cases := []struct {
+		trace   *api.DenomTrace
+		invalid bool
+	}{
+		{&api.DenomTrace{BaseDenom: "uatom"}, false},
+		{&api.DenomTrace{Path: "transfer/channel-1", BaseDenom: "uatom"}, false},
+		{&api.DenomTrace{Path: "transfer/channel-1/transfer/channel-2", BaseDenom: "uatom"}, false},
+		{&api.DenomTrace{Path: "customtransfer/channel-1", BaseDenom: "uatom"}, false},
+		{&api.DenomTrace{Path: "transfer/channel-1", BaseDenom: ""}, true},
+		{&api.DenomTrace{Path: "transfer/channel-1", BaseDenom: "  "}, true},
+		{&api.DenomTrace{}, true},
+		{&api.DenomTrace{Path: "transfer", BaseDenom: "uatom"}, true},
+		{&api.DenomTrace{Path: "transfer/channel-1/transfer", BaseDenom: "uatom"}, true},
+		{&api.DenomTrace{Path: "transfer/channel-1/transfer/", BaseDenom: "uatom"}, true},
+		{&api.DenomTrace{Path: "t/channel-1", BaseDenom: "uatom"}, true},
+		{&api.DenomTrace{Path: "transfer/chan", BaseDenom: "uatom"}, true},
+		{&api.DenomTrace{Path: "transfer/channel-1!", BaseDenom: "uatom"}, true},
+	}
+	for _, tc := range cases {
+		err := tc.trace.Validate()
+		if tc.invalid {
+			require.Error(t, err, "%v", tc.trace)
+		} else {
+			require.NoError(t, err, "%v", tc.trace)
+		}
+	}
+	require.ErrorIs(t, api.ValidateChannelID("chan"), api.ErrInvalidIdentifier)
+	require.NoError(t, api.ValidatePortID("transfer"))
+}
@@ -0,0 +1,91 @@
+// Command costor-plugin is a Cosmos SDK state streaming plugin which forwards every committed block to a costor
+// listener.  A node loads it with go-plugin, as configured in app.toml:
+//
+//	[streaming.abci]
+//	keys = ["*"]
+//	plugin = "costor"
+//	stop-node-on-err = true
+//
+// with COSMOS_SDK_COSTOR set to the path of this binary and COSTOR_LISTENER_SOCKET to the Unix socket a
+// listener.Server listens on.  A block is only acknowledged once the listener has handed its nodes on, so with
+// stop-node-on-err the node halts rather than commit blocks costor did not receive.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	streamingabci "cosmossdk.io/store/streaming/abci"
+	storetypes "cosmossdk.io/store/types"
+	abci "github.com/cometbft/cometbft/abci/types"
+	"github.com/hashicorp/go-plugin"
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/listener"
+)
+
+// socketEnv names the environment variable holding the path of the listener's socket.
+const socketEnv = "COSTOR_LISTENER_SOCKET"
+
+// costorListener implements the SDK's ABCIListener by sending change sets to a costor listener.
+type costorListener struct {
+	socket string
+
+	mu     sync.Mutex
+	height int64
+	client *listener.Client
+}
+
+var _ storetypes.ABCIListener = (*costorListener)(nil)
+
+// ListenFinalizeBlock records the height of the block, which ListenCommit is not given.
+func (l *costorListener) ListenFinalizeBlock(
+	_ context.Context, req abci.RequestFinalizeBlock, _ abci.ResponseFinalizeBlock,
+) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.height = req.Height
+	return nil
+}
+
+// ListenCommit sends the block's change set to the listener and waits for it to be acknowledged.  The connection is
+// made on first use and after any failure, so the listener may be restarted while the node runs.
+func (l *costorListener) ListenCommit(
+	_ context.Context, _ abci.ResponseCommit, changeSet []*storetypes.StoreKVPair,
+) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.client == nil {
+		client, err := listener.Dial(l.socket)
+		if err != nil {
+			return fmt.Errorf("block %d: %w", l.height, err)
+		}
+		l.client = client
+	}
+	pairs := make([]*api.StoreKVPair, len(changeSet))
+	for i, p := range changeSet {
+		pairs[i] = &api.StoreKVPair{StoreKey: p.StoreKey, Delete: p.Delete, Key: p.Key, Value: p.Value}
+	}
+	if err := l.client.ListenCommit(l.height, pairs); err != nil {
+		_ = l.client.Close()
+		l.client = nil
+		return err
+	}
+	return nil
+}
+
+func main() {
+	socket := os.Getenv(socketEnv)
+	if socket == "" {
+		fmt.Fprintf(os.Stderr, "%s is not set\n", socketEnv)
+		os.Exit(2)
+	}
+	plugin.Serve(&plugin.ServeConfig{
+		HandshakeConfig: streamingabci.Handshake,
+		Plugins: map[string]plugin.Plugin{
+			"abci": &streamingabci.ListenerGRPCPlugin{Impl: &costorListener{socket: socket}},
+		},
+		GRPCServer: plugin.DefaultGRPCServer,
+	})
+}
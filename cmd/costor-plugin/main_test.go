@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	storetypes "cosmossdk.io/store/types"
+	abci "github.com/cometbft/cometbft/abci/types"
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/compact"
+	"github.com/kocubinski/costor-api/listener"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCostorListener(t *testing.T) {
+	in := make(chan compact.Sequenced, 100)
+	socket := filepath.Join(t.TempDir(), "costor.sock")
+	server, err := listener.Listen(socket, in)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() {
+		served <- server.Serve(ctx)
+	}()
+
+	l := &costorListener{socket: socket}
+	for height := int64(1); height <= 3; height++ {
+		require.NoError(t, l.ListenFinalizeBlock(ctx, abci.RequestFinalizeBlock{Height: height},
+			abci.ResponseFinalizeBlock{}))
+		require.NoError(t, l.ListenCommit(ctx, abci.ResponseCommit{}, []*storetypes.StoreKVPair{
+			{StoreKey: "bank", Key: []byte(fmt.Sprintf("key-%d", height)), Value: []byte("v")},
+			{StoreKey: "acc", Key: []byte("gone"), Delete: true},
+		}))
+	}
+	require.Equal(t, int64(3), server.LastCommit())
+	require.Len(t, in, 6)
+	node := (<-in).(*api.Node)
+	require.Equal(t, &api.Node{StoreKey: "bank", Key: []byte("key-1"), Value: []byte("v"), Block: 1}, node)
+	node = (<-in).(*api.Node)
+	require.True(t, node.Delete)
+	require.Equal(t, "acc", node.StoreKey)
+
+	cancel()
+	require.NoError(t, <-served)
+	// the listener is gone; the plugin reports it and reconnects on the next block
+	require.NoError(t, l.ListenFinalizeBlock(ctx, abci.RequestFinalizeBlock{Height: 4}, abci.ResponseFinalizeBlock{}))
+	require.Error(t, l.ListenCommit(ctx, abci.ResponseCommit{}, nil))
+	require.Nil(t, l.client)
+}
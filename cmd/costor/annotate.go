@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kocubinski/costor-api/compact"
+)
+
+const annotateUsage = "annotate [-max-file-size bytes] -out dir dir"
+
+func annotate(args []string) int {
+	fs := flag.NewFlagSet("annotate", flag.ExitOnError)
+	outDir := fs.String("out", "", "directory the annotated segments are written to")
+	maxFileSize := fs.Int("max-file-size", 64*1024*1024, "size at which to start a new segment")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 || *outDir == "" {
+		fmt.Fprintln(os.Stderr, "usage: costor", annotateUsage)
+		return 2
+	}
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	stats, err := compact.Annotate(fs.Arg(0), &compact.StreamingContext{
+		OutDir:       *outDir,
+		MaxFileSize:  *maxFileSize,
+		OrderedInput: true,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Print(stats.Report())
+	return 0
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kocubinski/costor-api/decode"
+)
+
+const errorsUsage = "errors [-samples n] [-json file] dir..."
+
+func errorsReport(args []string) int {
+	fs := flag.NewFlagSet("errors", flag.ExitOnError)
+	samples := fs.Int("samples", 3, "number of sample errors to show per group")
+	jsonOut := fs.String("json", "", "also write the report as JSON to this file")
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: costor", errorsUsage)
+		return 2
+	}
+
+	report, err := decode.AnalyzeErrors(*samples, fs.Args()...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Print(report.Report())
+
+	if *jsonOut != "" {
+		bz, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if err := os.WriteFile(*jsonOut, bz, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,51 @@
+// Command costor inspects and maintains directories of compacted segments.
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/rs/zerolog"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) int
+}
+
+var commands = map[string]command{
+	"annotate": {usage: annotateUsage, run: annotate},
+	"errors":   {usage: errorsUsage, run: errorsReport},
+	"redecode": {usage: redecodeUsage, run: redecode},
+	"repair":   {usage: repairUsage, run: repair},
+	"verify":   {usage: verifyUsage, run: verify},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: costor <command> [arguments]")
+	fmt.Fprintln(os.Stderr, "commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	// per file progress logging drowns out the reports the commands print
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	os.Exit(cmd.run(os.Args[2:]))
+}
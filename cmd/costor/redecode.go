@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kocubinski/costor-api/compact"
+	"github.com/kocubinski/costor-api/decode"
+)
+
+const redecodeUsage = "redecode [-hrp prefix] [-descriptors dir] [-max-file-size bytes] [-json file] " +
+	"-out dir -errors dir dir"
+
+func redecode(args []string) int {
+	fs := flag.NewFlagSet("redecode", flag.ExitOnError)
+	outDir := fs.String("out", "", "directory the nodes which now decode are written to")
+	errorsDir := fs.String("errors", "", "directory the remaining decode errors are written to")
+	hrp := fs.String("hrp", "cosmos", "bech32 human readable part addresses are rendered with; empty for hex")
+	descriptors := fs.String("descriptors", "", "directory of descriptor sets and their "+decode.TypesFile+
+		" to decode values with")
+	maxFileSize := fs.Int("max-file-size", 64*1024*1024, "size at which to start a new segment")
+	jsonOut := fs.String("json", "", "also write the report as JSON to this file")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 || *outDir == "" || *errorsDir == "" {
+		fmt.Fprintln(os.Stderr, "usage: costor", redecodeUsage)
+		return 2
+	}
+	for _, dir := range []string{*outDir, *errorsDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	r := decode.NewSDKRegistry(*hrp)
+	if *descriptors != "" {
+		decoders, err := decode.LoadDynamic(*descriptors)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, d := range decoders {
+			if err := r.Register(d); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+		}
+	}
+
+	stats, err := decode.Redecode(fs.Arg(0), r,
+		&compact.StreamingContext{OutDir: *outDir, MaxFileSize: *maxFileSize, OrderedInput: true},
+		&compact.StreamingContext{OutDir: *errorsDir, MaxFileSize: *maxFileSize, OrderedInput: true},
+	)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Print(stats.Report())
+
+	if *jsonOut != "" {
+		bz, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if err := os.WriteFile(*jsonOut, bz, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return 0
+}
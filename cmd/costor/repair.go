@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/compact"
+	"github.com/kocubinski/costor-api/core"
+)
+
+const repairUsage = "repair [-type node|error|envelope] [-quarantine dir] [-dry-run] [-json file] dir"
+
+func repair(args []string) int {
+	fs := flag.NewFlagSet("repair", flag.ExitOnError)
+	recordType := fs.String("type", "node", "record type held in the segments: node, error or envelope")
+	quarantine := fs.String("quarantine", "", "directory damaged segments are moved to (default <dir>/quarantine)")
+	dryRun := fs.Bool("dry-run", false, "report what would be repaired without changing any files")
+	jsonOut := fs.String("json", "", "also write the report as JSON to this file")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: costor", repairUsage)
+		return 2
+	}
+	dir := fs.Arg(0)
+	if *quarantine == "" {
+		*quarantine = filepath.Join(dir, "quarantine")
+	}
+
+	ctx := core.Context{DryRun: *dryRun}
+	var (
+		report *compact.RepairReport
+		err    error
+	)
+	switch *recordType {
+	case "node":
+		report, err = compact.Repair(ctx, dir, *quarantine, func() *api.Node { return &api.Node{} })
+	case "error":
+		report, err = compact.Repair(ctx, dir, *quarantine, func() *api.DecodeError { return &api.DecodeError{} })
+	case "envelope":
+		report, err = compact.Repair(ctx, dir, *quarantine, func() *api.Envelope { return &api.Envelope{} })
+	default:
+		fmt.Fprintf(os.Stderr, "unknown record type %q\n", *recordType)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Print(report.Report())
+
+	if *jsonOut != "" {
+		bz, err := json.MarshalIndent(struct {
+			*compact.RepairReport
+			LostBlocks []compact.BlockRange `json:"lost_blocks"`
+		}{report, report.LostBlocks()}, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if err := os.WriteFile(*jsonOut, bz, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/compact"
+)
+
+const verifyUsage = "verify [-type node|error|envelope] dir..."
+
+func verify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	recordType := fs.String("type", "node", "record type held in the segments: node, error or envelope")
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: costor", verifyUsage)
+		return 2
+	}
+
+	status := 0
+	for _, dir := range fs.Args() {
+		var (
+			report *compact.VerifyReport
+			err    error
+		)
+		switch *recordType {
+		case "node":
+			report, err = compact.Verify(dir, func() *api.Node { return &api.Node{} })
+		case "error":
+			report, err = compact.Verify(dir, func() *api.DecodeError { return &api.DecodeError{} })
+		case "envelope":
+			report, err = compact.Verify(dir, func() *api.Envelope { return &api.Envelope{} })
+		default:
+			fmt.Fprintf(os.Stderr, "unknown record type %q\n", *recordType)
+			return 2
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Print(report.Report())
+		if !report.OK() {
+			status = 1
+		}
+	}
+	return status
+}
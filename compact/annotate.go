@@ -0,0 +1,165 @@
+package compact
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	api "github.com/kocubinski/costor-api"
+)
+
+// AnnotateStats summarizes an Annotate pass.
+type AnnotateStats struct {
+	Dir     string
+	Writes  int64
+	Deletes int64
+	// Orphaned is the number of writes which were overwritten or deleted; the rest are still live.
+	Orphaned int64
+	Files    []string
+}
+
+func (s *AnnotateStats) Report() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("annotate %s:\n", s.Dir))
+	sb.WriteString(fmt.Sprintf("writes: %s\n", humanize.Comma(s.Writes)))
+	sb.WriteString(fmt.Sprintf("deletes: %s\n", humanize.Comma(s.Deletes)))
+	sb.WriteString(fmt.Sprintf("orphaned: %s\n", humanize.Comma(s.Orphaned)))
+	sb.WriteString(fmt.Sprintf("live: %s\n", humanize.Comma(s.Writes-s.Orphaned)))
+	sb.WriteString(fmt.Sprintf("files written: %d\n", len(s.Files)))
+	return sb.String()
+}
+
+// Annotate reads the changeset directory dir and writes every node to out with its lifetime filled in:
+//   - FirstVersion is the version the write became live, its Block
+//   - LastVersion is the version the key was next written or deleted at, which orphaned the write, or 0 if the write
+//     is still live at the end of dir
+//
+// A key written twice in one block orphans the first write at the version it was written.  Deletes are not live
+// writes and are copied with both versions left 0.
+//
+// Annotate reads dir twice.  The first pass keeps the position of the last write of every live key in memory, and
+// records the version each orphaned write was orphaned at in a temporary file in out.OutDir; the second pass
+// fills in the nodes and sends them to out.Compact.  out.In is created if nil and closed by Annotate.  If Annotate
+// fails, the files it wrote to out are removed.
+func Annotate(dir string, out *StreamingContext) (*AnnotateStats, error) {
+	stats := &AnnotateStats{Dir: dir}
+	newNode := func() *api.Node { return &api.Node{} }
+
+	// lastVersions holds the version of the i-th record of dir at offset 8*i.  Only orphaned writes are stored; the
+	// file is sparse and reads 0 everywhere else, including past its end.  It is kept next to the output rather than
+	// in the system temporary directory, which may be in memory.
+	lastVersions, err := os.CreateTemp(out.OutDir, "annotate-*"+tempSuffix)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		lastVersions.Close()
+		os.Remove(lastVersions.Name())
+	}()
+
+	var (
+		lastWrite = make(map[string]map[string]int64)
+		pos       int64
+		versionBz [8]byte
+	)
+	itr, err := NewSequencedIterator(dir, newNode, WithReuseNode())
+	for ; err == nil && itr.Valid(); err = itr.Next() {
+		node := itr.Node
+		keys := lastWrite[node.StoreKey]
+		if keys == nil {
+			keys = make(map[string]int64)
+			lastWrite[node.StoreKey] = keys
+		}
+		if i, ok := keys[string(node.Key)]; ok {
+			binary.LittleEndian.PutUint64(versionBz[:], uint64(node.Block))
+			if _, err = lastVersions.WriteAt(versionBz[:], 8*i); err != nil {
+				break
+			}
+			delete(keys, string(node.Key))
+			stats.Orphaned++
+		}
+		if node.Delete {
+			stats.Deletes++
+		} else {
+			keys[string(node.Key)] = pos
+			stats.Writes++
+		}
+		pos++
+	}
+	if err != nil {
+		return nil, err
+	}
+	lastWrite = nil
+	if _, err := lastVersions.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	versions := bufio.NewReader(lastVersions)
+
+	if out.In == nil {
+		out.In = make(chan Sequenced)
+	}
+	type result struct {
+		stats *Stats
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		stats, err := out.Compact()
+		done <- result{stats, err}
+	}()
+	var (
+		res         result
+		compactDone bool
+	)
+	itr, err = NewSequencedIterator(dir, newNode)
+	for i := int64(0); err == nil && itr.Valid(); err = itr.Next() {
+		node := itr.Node
+		if i >= pos {
+			err = fmt.Errorf("%s changed while it was being annotated", dir)
+			break
+		}
+		var lastVersion int64
+		if _, err = io.ReadFull(versions, versionBz[:]); err == nil {
+			lastVersion = int64(binary.LittleEndian.Uint64(versionBz[:]))
+		} else if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = nil
+		} else {
+			break
+		}
+		if !node.Delete {
+			node.FirstVersion = node.Block
+			node.LastVersion = lastVersion
+		}
+		select {
+		case out.In <- node:
+		case res = <-done:
+			// Compact only returns early on error
+			compactDone = true
+		}
+		if compactDone {
+			break
+		}
+		i++
+	}
+	close(out.In)
+	if !compactDone {
+		res = <-done
+	}
+	if err == nil {
+		err = res.err
+	}
+	if err != nil {
+		if res.stats != nil {
+			for _, f := range res.stats.FilesWritten {
+				os.Remove(f)
+			}
+		}
+		return nil, err
+	}
+	stats.Files = res.stats.FilesWritten
+	return stats, nil
+}
@@ -0,0 +1,112 @@
+package compact_test
+
+import (
+	"crypto/rand"
+	"sync"
+	"testing"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/compact"
+	"github.com/stretchr/testify/require"
+)
+
+const benchNodes = 100_000
+
+// writeBenchDataset compacts benchNodes random nodes into a temporary directory and returns it.
+func writeBenchDataset(b *testing.B) string {
+	dir := b.TempDir()
+	ctx := compact.StreamingContext{
+		OutDir:       dir,
+		MaxFileSize:  1024 * 1024 * 100,
+		OrderedInput: true,
+		In:           make(chan compact.Sequenced),
+	}
+	done := make(chan error)
+	go func() {
+		_, err := ctx.Compact()
+		done <- err
+	}()
+	for i := 0; i < benchNodes; i++ {
+		k := make([]byte, 20)
+		v := make([]byte, 100)
+		_, err := rand.Read(k)
+		require.NoError(b, err)
+		_, err = rand.Read(v)
+		require.NoError(b, err)
+		ctx.In <- &api.Node{Key: k, Value: v, Block: int64(i/10) + 1, StoreKey: "bank"}
+	}
+	close(ctx.In)
+	require.NoError(b, <-done)
+	return dir
+}
+
+func benchmarkRead(b *testing.B, opts ...compact.IteratorOption) {
+	benchmarkReadCount(b, benchNodes, opts...)
+}
+
+func benchmarkReadCount(b *testing.B, expected int, opts ...compact.IteratorOption) {
+	dir := writeBenchDataset(b)
+	streamCtx := &compact.StreamingContext{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		itr, err := streamCtx.NewIterator(dir, opts...)
+		require.NoError(b, err)
+		cnt := 0
+		for ; itr.Valid(); err = itr.Next() {
+			require.NoError(b, err)
+			cnt++
+		}
+		require.Equal(b, expected, cnt)
+	}
+}
+
+func BenchmarkRead(b *testing.B) {
+	benchmarkRead(b)
+}
+
+func BenchmarkRead_ReuseNode(b *testing.B) {
+	benchmarkRead(b, compact.WithReuseNode())
+}
+
+func BenchmarkRead_NodePool(b *testing.B) {
+	pool := &sync.Pool{New: func() any { return &api.Node{} }}
+	benchmarkRead(b, compact.WithNodePool(pool))
+}
+
+// BenchmarkRead_Filtered skips every record before unmarshalling it.
+func BenchmarkRead_Filtered(b *testing.B) {
+	benchmarkReadCount(b, 0, compact.WithStoreKeys("staking"))
+}
+
+func BenchmarkCompact(b *testing.B) {
+	nodes := make([]*api.Node, benchNodes)
+	for i := range nodes {
+		k := make([]byte, 20)
+		v := make([]byte, 100)
+		_, err := rand.Read(k)
+		require.NoError(b, err)
+		_, err = rand.Read(v)
+		require.NoError(b, err)
+		nodes[i] = &api.Node{Key: k, Value: v, Block: int64(i/10) + 1, StoreKey: "bank"}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ctx := compact.StreamingContext{
+			OutDir:       b.TempDir(),
+			MaxFileSize:  1024 * 1024 * 100,
+			OrderedInput: true,
+			In:           make(chan compact.Sequenced, 1024),
+		}
+		go func() {
+			for _, node := range nodes {
+				ctx.In <- node
+			}
+			close(ctx.In)
+		}()
+		stats, err := ctx.Compact()
+		require.NoError(b, err)
+		require.Equal(b, benchNodes, stats.NodeCount)
+	}
+}
@@ -0,0 +1,389 @@
+package compact
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"os"
+
+	api "github.com/kocubinski/costor-api"
+	"google.golang.org/protobuf/proto"
+)
+
+// BlockIterator iterates over the records of one block in a directory.  It pauses on the first record of the next
+// block; see GroupedIterator.
+type BlockIterator[T Sequenced] struct {
+	// the store key set on each record, see WithStoreKey
+	StoreKey string
+
+	itr     *SequencedIterator[T]
+	paused  bool
+	version int64
+}
+
+// StoreKeyedIterator iterates over the nodes of one changeset.
+type StoreKeyedIterator = BlockIterator[*api.Node]
+
+var _ api.NodeIterator = (*StoreKeyedIterator)(nil)
+
+func (s *BlockIterator[T]) Next() error {
+	if s.paused {
+		return nil
+	}
+	if err := s.itr.Next(); err != nil {
+		return err
+	}
+
+	if s.itr.Node.Sequence() > s.version {
+		s.paused = true
+	}
+
+	return nil
+}
+
+func (s *BlockIterator[T]) Valid() bool {
+	if s == nil {
+		return false
+	}
+	if s.itr == nil {
+		return false
+	}
+	return !s.paused && s.itr.Valid()
+}
+
+func (s *BlockIterator[T]) GetNode() T {
+	if s.paused {
+		var zero T
+		return zero
+	}
+	return s.itr.Node
+}
+
+// GroupedIterator iterates over the records in a directory one block at a time.  For each block the records are read
+// from Records until it is exhausted, then Next moves to the following block.
+type GroupedIterator[T Sequenced] struct {
+	records *BlockIterator[T]
+}
+
+func NewGroupedIterator[T Sequenced](dir string, newNode func() T, storeKey ...string) (*GroupedIterator[T], error) {
+	var opts []IteratorOption
+	if len(storeKey) > 0 {
+		opts = append(opts, WithStoreKey(storeKey[0]))
+	}
+	return NewGroupedIteratorWithOptions(dir, newNode, opts...)
+}
+
+func NewGroupedIteratorWithOptions[T Sequenced](
+	dir string, newNode func() T, opts ...IteratorOption,
+) (*GroupedIterator[T], error) {
+	itr, err := NewSequencedIterator(dir, newNode, opts...)
+	if err != nil {
+		return nil, err
+	}
+	blockItr := &BlockIterator[T]{itr: itr, paused: true, StoreKey: itr.opts.storeKey}
+	groupItr := &GroupedIterator[T]{
+		records: blockItr,
+	}
+	err = groupItr.Next()
+	if err != nil {
+		return nil, err
+	}
+	return groupItr, nil
+}
+
+func (it *GroupedIterator[T]) Next() error {
+	if it.records == nil {
+		return nil
+	}
+	if !it.records.paused {
+		if it.records.Valid() {
+			return fmt.Errorf("expected paused iterator")
+		}
+		it.records = nil
+		return nil
+	}
+	// the iterator is paused on the first record of the next block, or exhausted
+	if !it.records.itr.Valid() {
+		it.records = nil
+		return nil
+	}
+	it.records.paused = false
+	it.records.version = it.records.GetNode().Sequence()
+	return nil
+}
+
+func (it *GroupedIterator[T]) Valid() bool {
+	return it.records.Valid()
+}
+
+// Records returns an iterator over the records of the current block.
+func (it *GroupedIterator[T]) Records() *BlockIterator[T] {
+	return it.records
+}
+
+// reusesNodes returns true if records are only valid until the next record is read, as with WithReuseNode or
+// WithNodePool.
+func (it *GroupedIterator[T]) reusesNodes() bool {
+	if it.records == nil {
+		return false
+	}
+	o := it.records.itr.opts
+	return o.reuseNode || o.pool != nil
+}
+
+func (it *GroupedIterator[T]) Version() int64 {
+	if it.records == nil {
+		return 0
+	}
+	return it.records.version
+}
+
+// ChangesetIterator iterates over the nodes in a directory one changeset at a time.
+type ChangesetIterator struct {
+	*GroupedIterator[*api.Node]
+}
+
+func NewChangesetIterator(dir string, storeKey ...string) (*ChangesetIterator, error) {
+	var opts []IteratorOption
+	if len(storeKey) > 0 {
+		opts = append(opts, WithStoreKey(storeKey[0]))
+	}
+	return NewChangesetIteratorWithOptions(dir, opts...)
+}
+
+func NewChangesetIteratorWithOptions(dir string, opts ...IteratorOption) (*ChangesetIterator, error) {
+	itr, err := NewGroupedIteratorWithOptions(dir, func() *api.Node { return &api.Node{} }, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &ChangesetIterator{itr}, nil
+}
+
+func (it *ChangesetIterator) Nodes() api.NodeIterator {
+	return it.Records()
+}
+
+// MultiChangesetIterator merges the changesets of every store in a multistore directory, which holds one
+// subdirectory per store key, into one changeset per version.  Within a changeset nodes are grouped by store, in
+// store key order.
+type MultiChangesetIterator struct {
+	*api.Changeset
+	iterators changesetHeap
+}
+
+// NewMultiChangesetIterator opens every store in dir.  Stores excluded by a store key filter in opts are skipped
+// without being read; the remaining options apply to the iterator of each store.  WithReuseNode and WithNodePool are
+// rejected, as a changeset keeps every node of its version.
+func NewMultiChangesetIterator(dir string, opts ...IteratorOption) (*MultiChangesetIterator, error) {
+	multiItr := &MultiChangesetIterator{}
+	var o iteratorOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.reuseNode || o.pool != nil {
+		return nil, errors.New("multistore changesets keep their nodes, so nodes can't be reused or pooled")
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if !file.IsDir() {
+			return nil, fmt.Errorf("expected directory, got file: %s", file.Name())
+		}
+		if o.filter != nil && !o.filter.allowsStoreKey(file.Name()) {
+			continue
+		}
+		storeOpts := append(opts[:len(opts):len(opts)], WithStoreKey(file.Name()))
+		itr, err := NewChangesetIteratorWithOptions(fmt.Sprintf("%s/%s", dir, file.Name()), storeOpts...)
+		if err != nil {
+			return nil, err
+		}
+		if itr.Valid() {
+			multiItr.iterators = append(multiItr.iterators, storeChangesetIterator{itr, len(multiItr.iterators)})
+		}
+	}
+	heap.Init(&multiItr.iterators)
+	err = multiItr.Next()
+	if err != nil {
+		return nil, err
+	}
+	return multiItr, nil
+}
+
+func (it *MultiChangesetIterator) Next() error {
+	if len(it.iterators) == 0 {
+		it.Changeset = nil
+		return nil
+	}
+	cs := &api.Changeset{Version: it.iterators[0].Version()}
+	for len(it.iterators) > 0 && it.iterators[0].Version() == cs.Version {
+		itr := heap.Pop(&it.iterators).(storeChangesetIterator)
+		nodes := itr.Nodes()
+		var err error
+		for ; nodes.Valid(); err = nodes.Next() {
+			if err != nil {
+				return err
+			}
+			cs.Nodes = append(cs.Nodes, nodes.GetNode())
+		}
+		if err != nil {
+			return err
+		}
+		if err := itr.Next(); err != nil {
+			return err
+		}
+		if itr.Valid() {
+			heap.Push(&it.iterators, itr)
+		}
+	}
+	it.Changeset = cs
+	return nil
+}
+
+func (it *MultiChangesetIterator) Valid() bool {
+	return it.Changeset != nil
+}
+
+func (it *MultiChangesetIterator) Nodes() api.NodeIterator {
+	if it.Changeset == nil {
+		return (&api.Changeset{}).Iterator()
+	}
+	return it.Changeset.Iterator()
+}
+
+func (it *MultiChangesetIterator) Version() int64 {
+	if it.Changeset == nil {
+		return 0
+	}
+	return it.Changeset.Version
+}
+
+// storeChangesetIterator is a store's ChangesetIterator and the position of the store in key order.
+type storeChangesetIterator struct {
+	*ChangesetIterator
+	order int
+}
+
+// changesetHeap orders store iterators by version, then by store key.
+type changesetHeap []storeChangesetIterator
+
+func (h changesetHeap) Len() int { return len(h) }
+
+func (h changesetHeap) Less(i, j int) bool {
+	if h[i].Version() != h[j].Version() {
+		return h[i].Version() < h[j].Version()
+	}
+	return h[i].order < h[j].order
+}
+
+func (h changesetHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *changesetHeap) Push(x any) { *h = append(*h, x.(storeChangesetIterator)) }
+
+func (h *changesetHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// ErrChangesetTooLarge is returned by MaterializedIterator when a version's writes exceed MaxBytes.
+var ErrChangesetTooLarge = errors.New("changeset too large")
+
+// ChangesetSource is a changeset at a time iterator, such as ChangesetIterator or MultiChangesetIterator.
+type ChangesetSource interface {
+	Next() error
+	Valid() bool
+	Nodes() api.NodeIterator
+	Version() int64
+}
+
+var (
+	_ ChangesetSource = (*ChangesetIterator)(nil)
+	_ ChangesetSource = (*MultiChangesetIterator)(nil)
+)
+
+type MaterializeOptions struct {
+	// MaxBytes caps the marshalled size of the nodes held by one changeset.  Zero means no cap.
+	MaxBytes int64
+	// Split yields the writes of a version exceeding MaxBytes as several changesets, each marked Partial except the
+	// last, instead of failing with ErrChangesetTooLarge.
+	Split bool
+}
+
+// MaterializedIterator reads all of a version's nodes from a ChangesetSource into an *api.Changeset.
+type MaterializedIterator struct {
+	Changeset *api.Changeset
+
+	src     ChangesetSource
+	opts    MaterializeOptions
+	started bool
+	// nodes of the current version which have not been yielded yet
+	nodes   api.NodeIterator
+	version int64
+}
+
+// NewMaterializedIterator reads the changesets of src, which must yield nodes that stay valid after its Next, as they
+// are kept in the changeset.  A ChangesetIterator opened WithReuseNode or WithNodePool is rejected.
+func NewMaterializedIterator(src ChangesetSource, opts MaterializeOptions) (*MaterializedIterator, error) {
+	if cs, ok := src.(*ChangesetIterator); ok && cs.reusesNodes() {
+		return nil, errors.New("materialized changesets keep their nodes, so nodes can't be reused or pooled")
+	}
+	itr := &MaterializedIterator{src: src, opts: opts}
+	return itr, itr.Next()
+}
+
+func (it *MaterializedIterator) Valid() bool {
+	return it.Changeset != nil
+}
+
+func (it *MaterializedIterator) Next() error {
+	if it.nodes == nil {
+		if it.started {
+			if err := it.src.Next(); err != nil {
+				return err
+			}
+		}
+		it.started = true
+		if !it.src.Valid() {
+			it.Changeset = nil
+			return nil
+		}
+		it.nodes = it.src.Nodes()
+		it.version = it.src.Version()
+	}
+
+	cs := &api.Changeset{Version: it.version}
+	var (
+		size int64
+		err  error
+	)
+	for ; it.nodes.Valid(); err = it.nodes.Next() {
+		if err != nil {
+			return err
+		}
+		node := it.nodes.GetNode()
+		n := int64(proto.Size(node))
+		if it.opts.MaxBytes > 0 && size+n > it.opts.MaxBytes {
+			if !it.opts.Split {
+				return fmt.Errorf("%w: version %d exceeds %d bytes", ErrChangesetTooLarge, it.version, it.opts.MaxBytes)
+			}
+			// a single node larger than the cap is yielded on its own
+			if len(cs.Nodes) > 0 {
+				cs.Partial = true
+				it.Changeset = cs
+				return nil
+			}
+		}
+		size += n
+		cs.Nodes = append(cs.Nodes, node)
+	}
+	if err != nil {
+		return err
+	}
+	it.nodes = nil
+	it.Changeset = cs
+	return nil
+}
@@ -1,6 +1,7 @@
 package compact
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"encoding/binary"
@@ -46,6 +47,8 @@ type StreamingContext struct {
 
 	// Assume that the input is ordered by block height
 	OrderedInput bool
+	// Set the IAVL leaf hash of each Node written, alone or in an Envelope, which does not already have one
+	FillHash bool
 
 	minBlock int64
 	maxBlock int64
@@ -75,18 +78,30 @@ func (c *StreamingContext) nextFilename() (string, error) {
 	return filename, nil
 }
 
+// writeBufferSize is the size of the buffered writer placed in front of the compressor.
+const writeBufferSize = 64 * 1024
+
+// Compact writes the records of In to segments in OutDir until In is closed.  On error the returned stats still list
+// the segments written before it, so that a caller can remove them.
 func (c *StreamingContext) Compact() (*Stats, error) {
 	logger := logz.Logger.With().Str("module", "streaming").Logger()
 	c.minBlock = math.MaxInt64
 	c.maxBlock = 0
 	stats := &Stats{}
 	var (
-		buf    bytes.Buffer
-		gz     = gzip.NewWriter(&buf)
-		uzSize int
+		buf      bytes.Buffer
+		gz       = gzip.NewWriter(&buf)
+		bw       = bufio.NewWriterSize(gz, writeBufferSize)
+		uzSize   int
+		marshal  = proto.MarshalOptions{}
+		protoBz  []byte
+		lengthBz [4]byte
 	)
 
 	flush := func() error {
+		if err := bw.Flush(); err != nil {
+			return err
+		}
 		if buf.Len() == 0 {
 			return nil
 		}
@@ -100,7 +115,12 @@ func (c *StreamingContext) Compact() (*Stats, error) {
 		if err != nil {
 			return err
 		}
-		err = os.WriteFile(filename, buf.Bytes(), 0644)
+		// write under a temporary name so that readers following OutDir never see a partial segment
+		err = os.WriteFile(filename+tempSuffix, buf.Bytes(), 0644)
+		if err != nil {
+			return err
+		}
+		err = os.Rename(filename+tempSuffix, filename)
 		if err != nil {
 			return err
 		}
@@ -108,7 +128,7 @@ func (c *StreamingContext) Compact() (*Stats, error) {
 
 		stats.FilesWritten = append(stats.FilesWritten, filename)
 		buf.Reset()
-		gz = gzip.NewWriter(&buf)
+		gz.Reset(&buf)
 		uzSize = 0
 		c.FileSeq++
 		c.minBlock = math.MaxInt64
@@ -116,11 +136,6 @@ func (c *StreamingContext) Compact() (*Stats, error) {
 		return nil
 	}
 
-	var flushErr error
-	defer func() {
-		flushErr = flush()
-	}()
-
 	for node := range c.In {
 		stats.NodeCount++
 		seq := node.Sequence()
@@ -131,30 +146,53 @@ func (c *StreamingContext) Compact() (*Stats, error) {
 			c.maxBlock = seq
 		}
 
-		protoBz, err := proto.Marshal(node)
+		if c.FillHash {
+			fillHash(node)
+		}
+
+		var err error
+		protoBz, err = marshal.MarshalAppend(protoBz[:0], node)
 		if err != nil {
-			return nil, err
+			return stats, err
 		}
 		stats.BytesRead += int64(len(protoBz))
-		err = binary.Write(gz, binary.LittleEndian, uint32(len(protoBz)))
+		binary.LittleEndian.PutUint32(lengthBz[:], uint32(len(protoBz)))
+		_, err = bw.Write(lengthBz[:])
 		if err != nil {
-			return nil, err
+			return stats, err
 		}
 		uzSize += 4
 
-		_, err = gz.Write(protoBz)
+		_, err = bw.Write(protoBz)
 		if err != nil {
-			return nil, err
+			return stats, err
 		}
 		uzSize += len(protoBz)
 
 		if buf.Len() > c.MaxFileSize {
 			err := flush()
 			if err != nil {
-				return nil, err
+				return stats, err
 			}
 		}
 	}
 
-	return stats, flushErr
+	if err := flush(); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// fillHash sets the IAVL leaf hash of a Node, or of the Node in an Envelope, if it does not already have one.
+func fillHash(record Sequenced) {
+	switch r := record.(type) {
+	case *api.Node:
+		if len(r.Hash) == 0 {
+			r.FillHash()
+		}
+	case *api.Envelope:
+		if n := r.GetNode(); n != nil {
+			fillHash(n)
+		}
+	}
 }
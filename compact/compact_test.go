@@ -1,13 +1,21 @@
 package compact_test
 
 import (
+	"context"
 	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	api "github.com/kocubinski/costor-api"
 	"github.com/kocubinski/costor-api/compact"
+	"github.com/kocubinski/costor-api/core"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
 )
 
 func Test_WriteAndRead(t *testing.T) {
@@ -107,4 +115,868 @@ func Test_WriteAndRead(t *testing.T) {
 		}
 	}
 	require.Equal(t, iterations, cnt)
+	cnt = 0
+	errGroups, err := compact.NewGroupedIterator(errDir, func() *api.DecodeError { return &api.DecodeError{} }, "keyed")
+	require.NoError(t, err)
+	for ; errGroups.Valid(); err = errGroups.Next() {
+		require.NoError(t, err)
+		records := errGroups.Records()
+		for ; records.Valid(); err = records.Next() {
+			require.NoError(t, err)
+			decodeErr := records.GetNode()
+			require.Equal(t, "keyed", decodeErr.StoreKey)
+			require.Equal(t, "keyed", decodeErr.Node.StoreKey)
+			require.Equal(t, int64(cnt/10)+1, decodeErr.Node.Block)
+			require.Equal(t, decodeErr.Node.Block, errGroups.Version())
+			cnt++
+		}
+	}
+	require.Equal(t, iterations, cnt)
+}
+
+func Test_ReadReusedNodes(t *testing.T) {
+	dir := t.TempDir()
+	ctx := compact.StreamingContext{
+		OutDir:       dir,
+		MaxFileSize:  1024 * 1024 * 100,
+		OrderedInput: true,
+		In:           make(chan compact.Sequenced),
+	}
+	go func() {
+		for i := 0; i < 100; i++ {
+			ctx.In <- &api.Node{Key: []byte{byte(i)}, Block: int64(i) + 1, Delete: i%2 == 0}
+		}
+		close(ctx.In)
+	}()
+	_, err := ctx.Compact()
+	require.NoError(t, err)
+
+	pool := &sync.Pool{New: func() any { return &api.Node{} }}
+	for _, opt := range []compact.IteratorOption{compact.WithReuseNode(), compact.WithNodePool(pool)} {
+		itr, err := ctx.NewIterator(dir, opt)
+		require.NoError(t, err)
+		cnt := 0
+		for ; itr.Valid(); err = itr.Next() {
+			require.NoError(t, err)
+			require.Equal(t, []byte{byte(cnt)}, itr.Node.Key)
+			require.Equal(t, int64(cnt)+1, itr.Node.Block)
+			require.Equal(t, cnt%2 == 0, itr.Node.Delete)
+			cnt++
+		}
+		require.Equal(t, 100, cnt)
+	}
+}
+
+func Test_RecoverTruncatedSegment(t *testing.T) {
+	dir := t.TempDir()
+	ctx := compact.StreamingContext{
+		OutDir:       dir,
+		MaxFileSize:  16 * 1024,
+		OrderedInput: true,
+		In:           make(chan compact.Sequenced),
+	}
+	values := randomValues(t, 5_000, 100)
+	go func() {
+		for i, v := range values {
+			ctx.In <- &api.Node{Key: []byte{byte(i)}, Value: v, Block: int64(i/10) + 1}
+		}
+		close(ctx.In)
+	}()
+	stats, err := ctx.Compact()
+	require.NoError(t, err)
+	require.Greater(t, len(stats.FilesWritten), 2)
+
+	// cut the second segment in half
+	damaged := stats.FilesWritten[1]
+	bz, err := os.ReadFile(damaged)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(damaged, bz[:len(bz)/2], 0644))
+
+	_, err = drain(ctx.NewIterator(dir))
+	require.Error(t, err)
+
+	itr, err := ctx.NewIterator(dir, compact.WithRecovery(false))
+	require.NoError(t, err)
+	cnt, err := drain(itr, err)
+	var terr *compact.TruncationError
+	require.ErrorAs(t, err, &terr)
+	require.Equal(t, damaged, terr.File)
+	require.Greater(t, terr.Offset, int64(0))
+	require.Equal(t, []*compact.TruncationError{terr}, itr.Truncations)
+	first := cnt
+
+	itr, err = ctx.NewIterator(dir, compact.WithRecovery(true))
+	require.NoError(t, err)
+	cnt, err = drain(itr, err)
+	require.NoError(t, err)
+	require.Len(t, itr.Truncations, 1)
+	require.Equal(t, terr.Record, itr.Truncations[0].Record)
+	require.Greater(t, cnt, first)
+	require.Less(t, cnt, 5_000)
+}
+
+// randomValues returns n values of size random bytes.  Producer goroutines take their values from it, as only the test
+// goroutine may fail the test.
+func randomValues(t *testing.T, n, size int) [][]byte {
+	values := make([][]byte, n)
+	for i := range values {
+		values[i] = make([]byte, size)
+		_, err := rand.Read(values[i])
+		require.NoError(t, err)
+	}
+	return values
+}
+
+func drain(itr *compact.SequencedIterator[*api.Node], err error) (int, error) {
+	if err != nil {
+		return 0, err
+	}
+	cnt := 0
+	for ; itr.Valid(); err = itr.Next() {
+		if err != nil {
+			return cnt, err
+		}
+		cnt++
+	}
+	return cnt, err
+}
+
+func Test_Verify(t *testing.T) {
+	dir := t.TempDir()
+	ctx := compact.StreamingContext{
+		OutDir:       dir,
+		MaxFileSize:  16 * 1024,
+		OrderedInput: true,
+		In:           make(chan compact.Sequenced),
+	}
+	values := randomValues(t, 5_000, 100)
+	go func() {
+		for i, v := range values {
+			ctx.In <- &api.Node{Key: []byte{byte(i)}, Value: v, Block: int64(i/10) + 1}
+		}
+		close(ctx.In)
+	}()
+	stats, err := ctx.Compact()
+	require.NoError(t, err)
+	require.Greater(t, len(stats.FilesWritten), 3)
+	newNode := func() *api.Node { return &api.Node{} }
+
+	report, err := compact.Verify(dir, newNode)
+	require.NoError(t, err)
+	require.True(t, report.OK(), report.Report())
+	require.Equal(t, 5_000, report.Records)
+	require.Len(t, report.Segments, len(stats.FilesWritten))
+
+	report, err = compact.Verify(dir, func() *api.DecodeError { return &api.DecodeError{} })
+	require.NoError(t, err)
+	require.False(t, report.OK())
+
+	require.NoError(t, os.Remove(stats.FilesWritten[1]))
+	bz, err := os.ReadFile(stats.FilesWritten[2])
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(stats.FilesWritten[2], bz[:len(bz)-10], 0644))
+	report, err = compact.Verify(dir, newNode)
+	require.NoError(t, err)
+	require.Len(t, report.Problems, 2, report.Report())
+	require.Equal(t, stats.FilesWritten[2], report.Problems[0].File)
+	require.Contains(t, report.Problems[0].Reason, "unreadable")
+	require.Contains(t, report.Problems[1].Reason, "missing blocks")
+}
+
+func Test_Repair(t *testing.T) {
+	dir := t.TempDir()
+	ctx := compact.StreamingContext{
+		OutDir:       dir,
+		MaxFileSize:  16 * 1024,
+		OrderedInput: true,
+		In:           make(chan compact.Sequenced),
+	}
+	values := randomValues(t, 5_000, 100)
+	go func() {
+		for i, v := range values {
+			ctx.In <- &api.Node{Key: []byte{byte(i)}, Value: v, Block: int64(i/10) + 1}
+		}
+		close(ctx.In)
+	}()
+	stats, err := ctx.Compact()
+	require.NoError(t, err)
+	newNode := func() *api.Node { return &api.Node{} }
+
+	damaged := stats.FilesWritten[1]
+	bz, err := os.ReadFile(damaged)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(damaged, bz[:len(bz)/2], 0644))
+
+	quarantine := filepath.Join(t.TempDir(), "quarantine")
+	report, err := compact.Repair(core.Context{DryRun: true}, dir, quarantine, newNode)
+	require.NoError(t, err)
+	require.Len(t, report.Segments, 1)
+	require.True(t, api.IsFileExistent(damaged))
+
+	report, err = compact.Repair(core.Context{}, dir, quarantine, newNode)
+	require.NoError(t, err)
+	require.Len(t, report.Segments, 1)
+	seg := report.Segments[0]
+	require.Equal(t, damaged, seg.File)
+	require.True(t, api.IsFileExistent(seg.Quarantined))
+	require.False(t, api.IsFileExistent(damaged))
+	require.Len(t, seg.Rewritten, 1)
+	lost := report.LostBlocks()
+	require.Len(t, lost, 1)
+
+	verified, err := compact.Verify(dir, newNode)
+	require.NoError(t, err)
+	require.Len(t, verified.Problems, 1, verified.Report())
+	require.Contains(t, verified.Problems[0].Reason, fmt.Sprintf("missing blocks %d", lost[0].First))
+
+	// every block outside of the lost range is complete
+	itr, err := ctx.NewIterator(dir)
+	require.NoError(t, err)
+	blocks := map[int64]int{}
+	for ; itr.Valid(); err = itr.Next() {
+		require.NoError(t, err)
+		blocks[itr.Node.Block]++
+	}
+	for block := int64(1); block <= 500; block++ {
+		if block >= lost[0].First && block <= lost[0].Last {
+			continue
+		}
+		require.Equal(t, 10, blocks[block], "block %d", block)
+	}
+}
+
+func Test_RepairSplitBlock(t *testing.T) {
+	// a block too large for one segment is written as <block>, <block>-<file seq>, ...; the file seq is either below
+	// the block or not, which makes the name look like a block range
+	for _, block := range []int64{100, 1} {
+		t.Run(fmt.Sprintf("block %d", block), func(t *testing.T) {
+			dir := t.TempDir()
+			ctx := compact.StreamingContext{
+				OutDir:       dir,
+				MaxFileSize:  16 * 1024,
+				OrderedInput: true,
+				In:           make(chan compact.Sequenced),
+			}
+			values := randomValues(t, 2_000, 100)
+			go func() {
+				for i, v := range values {
+					ctx.In <- &api.Node{Key: []byte{byte(i)}, Value: v, Block: block}
+				}
+				ctx.In <- &api.Node{Key: []byte{0}, Value: []byte{0}, Block: block + 1}
+				close(ctx.In)
+			}()
+			stats, err := ctx.Compact()
+			require.NoError(t, err)
+			require.Greater(t, len(stats.FilesWritten), 3)
+
+			damaged := stats.FilesWritten[2]
+			require.Equal(t, fmt.Sprintf("%08d-%08d.pb.gz", block, 2), filepath.Base(damaged))
+			bz, err := os.ReadFile(damaged)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(damaged, bz[:len(bz)/2], 0644))
+
+			report, err := compact.Repair(core.Context{}, dir, filepath.Join(t.TempDir(), "quarantine"),
+				func() *api.Node { return &api.Node{} })
+			require.NoError(t, err)
+			require.Len(t, report.Segments, 1)
+			require.Equal(t, &compact.BlockRange{First: block, Last: block}, report.Segments[0].Lost)
+			require.Equal(t, []compact.BlockRange{{First: block, Last: block}}, report.LostBlocks())
+		})
+	}
+}
+
+func Test_RepairRangeAfterSingleBlock(t *testing.T) {
+	dir := t.TempDir()
+	ctx := compact.StreamingContext{
+		OutDir:       dir,
+		MaxFileSize:  16 * 1024,
+		OrderedInput: true,
+		In:           make(chan compact.Sequenced),
+	}
+	// block 1 fills the first segment, which is named after it alone, and continues into the second
+	const firstBlock = 700
+	values := randomValues(t, firstBlock+2_000, 100)
+	go func() {
+		for i, v := range values {
+			block := int64(1)
+			if i >= firstBlock {
+				block = int64(i-firstBlock)/10 + 2
+			}
+			ctx.In <- &api.Node{Key: []byte{byte(i)}, Value: v, Block: block}
+		}
+		close(ctx.In)
+	}()
+	stats, err := ctx.Compact()
+	require.NoError(t, err)
+	require.Greater(t, len(stats.FilesWritten), 2)
+	require.Equal(t, "00000001.pb.gz", filepath.Base(stats.FilesWritten[0]))
+
+	damaged := stats.FilesWritten[1]
+	var last int64
+	_, err = fmt.Sscanf(filepath.Base(damaged), "00000001-%08d.pb.gz", &last)
+	require.NoError(t, err)
+	bz, err := os.ReadFile(damaged)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(damaged, bz[:len(bz)/2], 0644))
+
+	newNode := func() *api.Node { return &api.Node{} }
+	report, err := compact.Repair(core.Context{}, dir, filepath.Join(t.TempDir(), "quarantine"), newNode)
+	require.NoError(t, err)
+	require.Len(t, report.Segments, 1)
+	lost := report.Segments[0].Lost
+	require.NotNil(t, lost)
+	require.Greater(t, lost.First, int64(1))
+	require.Equal(t, last, lost.Last)
+
+	itr, err := ctx.NewIterator(dir)
+	require.NoError(t, err)
+	blocks := map[int64]int{}
+	for ; itr.Valid(); err = itr.Next() {
+		require.NoError(t, err)
+		blocks[itr.Node.Block]++
+	}
+	require.NoError(t, err)
+	require.Equal(t, firstBlock, blocks[1])
+	for block := int64(2); block < lost.First; block++ {
+		require.Equal(t, 10, blocks[block], "block %d", block)
+	}
+}
+
+func Test_RepairRewriteFails(t *testing.T) {
+	dir := t.TempDir()
+	ctx := compact.StreamingContext{
+		OutDir:       dir,
+		MaxFileSize:  16 * 1024,
+		OrderedInput: true,
+		In:           make(chan compact.Sequenced),
+	}
+	values := randomValues(t, 5_000, 100)
+	go func() {
+		for i, v := range values {
+			ctx.In <- &api.Node{Key: []byte{byte(i)}, Value: v, Block: int64(i/10) + 1}
+		}
+		close(ctx.In)
+	}()
+	stats, err := ctx.Compact()
+	require.NoError(t, err)
+	newNode := func() *api.Node { return &api.Node{} }
+
+	damaged := stats.FilesWritten[1]
+	bz, err := os.ReadFile(damaged)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(damaged, bz[:len(bz)/2], 0644))
+
+	quarantine := filepath.Join(t.TempDir(), "quarantine")
+	report, err := compact.Repair(core.Context{DryRun: true}, dir, quarantine, newNode)
+	require.NoError(t, err)
+	require.Len(t, report.Segments, 1)
+	var first int64
+	_, err = fmt.Sscanf(filepath.Base(damaged), "%08d-", &first)
+	require.NoError(t, err)
+
+	// a directory in the place of the rewritten segment fails Compact; it is not read as a segment
+	rewritten := fmt.Sprintf("%08d-%08d.pb.gz", first, report.Segments[0].Lost.First-1)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, rewritten), 0755))
+	_, err = compact.Repair(core.Context{}, dir, quarantine, newNode)
+	require.Error(t, err)
+	restored, err := os.ReadFile(damaged)
+	require.NoError(t, err)
+	require.Equal(t, bz[:len(bz)/2], restored)
+}
+
+func Test_Follow(t *testing.T) {
+	dir := t.TempDir()
+	// an in-progress segment is never read
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000001.pb.gz.tmp"), []byte("partial"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	streamCtx := compact.StreamingContext{
+		OutDir:       dir,
+		MaxFileSize:  16 * 1024,
+		OrderedInput: true,
+		In:           make(chan compact.Sequenced),
+	}
+	values := randomValues(t, 5_000, 100)
+	go func() {
+		for i, v := range values {
+			streamCtx.In <- &api.Node{Key: []byte{byte(i)}, Value: v, Block: int64(i/10) + 1}
+			if i%1_000 == 0 {
+				time.Sleep(20 * time.Millisecond)
+			}
+		}
+		close(streamCtx.In)
+	}()
+	compactErr := make(chan error, 1)
+	go func() {
+		_, err := streamCtx.Compact()
+		compactErr <- err
+	}()
+
+	itr, err := streamCtx.NewIterator(dir, compact.WithFollow(ctx, 5*time.Millisecond))
+	require.NoError(t, err)
+	cnt := 0
+	for ; itr.Valid(); err = itr.Next() {
+		require.NoError(t, err)
+		require.Equal(t, int64(cnt/10)+1, itr.Node.Block)
+		cnt++
+		if cnt == 5_000 {
+			cancel()
+		}
+	}
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, 5_000, cnt)
+	require.NoError(t, <-compactErr)
+}
+
+func Test_MultiChangesetIterator(t *testing.T) {
+	dir := t.TempDir()
+	writeStore := func(storeKey string, blocks []int64) {
+		storeDir := filepath.Join(dir, storeKey)
+		require.NoError(t, os.Mkdir(storeDir, 0755))
+		ctx := compact.StreamingContext{
+			OutDir:       storeDir,
+			MaxFileSize:  1024,
+			OrderedInput: true,
+			In:           make(chan compact.Sequenced),
+		}
+		go func() {
+			for _, block := range blocks {
+				for i := 0; i < 3; i++ {
+					ctx.In <- &api.Node{Key: []byte{byte(i)}, Value: []byte(storeKey), Block: block}
+				}
+			}
+			close(ctx.In)
+		}()
+		_, err := ctx.Compact()
+		require.NoError(t, err)
+	}
+	var bank, staking []int64
+	for b := int64(1); b <= 50; b++ {
+		bank = append(bank, b)
+	}
+	for b := int64(10); b <= 80; b += 2 {
+		staking = append(staking, b)
+	}
+	writeStore("bank", bank)
+	writeStore("empty", nil)
+	writeStore("staking", staking)
+
+	itr, err := compact.NewMultiChangesetIterator(dir)
+	require.NoError(t, err)
+	var versions []int64
+	for ; itr.Valid(); err = itr.Next() {
+		require.NoError(t, err)
+		version := itr.Version()
+		versions = append(versions, version)
+		var stores []string
+		nodes := itr.Nodes()
+		for ; nodes.Valid(); err = nodes.Next() {
+			require.NoError(t, err)
+			node := nodes.GetNode()
+			require.Equal(t, version, node.Block)
+			require.Equal(t, string(node.Value), node.StoreKey)
+			stores = append(stores, node.StoreKey)
+		}
+		var expected []string
+		if version <= 50 {
+			expected = append(expected, "bank", "bank", "bank")
+		}
+		if version >= 10 && version%2 == 0 {
+			expected = append(expected, "staking", "staking", "staking")
+		}
+		require.Equal(t, expected, stores, "version %d", version)
+	}
+	require.Len(t, versions, 50+15)
+	require.Equal(t, int64(1), versions[0])
+	require.Equal(t, int64(80), versions[len(versions)-1])
+
+	itr, err = compact.NewMultiChangesetIterator(dir, compact.WithStoreKeys("staking"))
+	require.NoError(t, err)
+	versions = nil
+	for ; itr.Valid(); err = itr.Next() {
+		require.NoError(t, err)
+		versions = append(versions, itr.Version())
+		nodes := itr.Nodes()
+		for ; nodes.Valid(); err = nodes.Next() {
+			require.NoError(t, err)
+			require.Equal(t, "staking", nodes.GetNode().StoreKey)
+		}
+	}
+	require.Len(t, versions, 36)
+
+	_, err = compact.NewMultiChangesetIterator(dir, compact.WithReuseNode())
+	require.Error(t, err)
+	_, err = compact.NewMultiChangesetIterator(dir, compact.WithNodePool(&sync.Pool{}))
+	require.Error(t, err)
+}
+
+func Test_MaterializedIterator(t *testing.T) {
+	dir := t.TempDir()
+	ctx := compact.StreamingContext{
+		OutDir:       dir,
+		MaxFileSize:  1024 * 1024,
+		OrderedInput: true,
+		In:           make(chan compact.Sequenced),
+	}
+	go func() {
+		for block := int64(1); block <= 10; block++ {
+			// block n holds n nodes
+			for i := int64(0); i < block; i++ {
+				ctx.In <- &api.Node{Key: []byte{byte(i)}, Value: make([]byte, 100), Block: block}
+			}
+		}
+		close(ctx.In)
+	}()
+	_, err := ctx.Compact()
+	require.NoError(t, err)
+
+	src, err := compact.NewChangesetIterator(dir)
+	require.NoError(t, err)
+	itr, err := compact.NewMaterializedIterator(src, compact.MaterializeOptions{})
+	require.NoError(t, err)
+	version := int64(1)
+	for ; itr.Valid(); err = itr.Next() {
+		require.NoError(t, err)
+		require.Equal(t, version, itr.Changeset.Version)
+		require.Len(t, itr.Changeset.Nodes, int(version))
+		require.False(t, itr.Changeset.Partial)
+		version++
+	}
+	require.Equal(t, int64(11), version)
+
+	// room for 4 nodes per changeset
+	maxBytes := int64(4*proto.Size(&api.Node{Key: []byte{0}, Value: make([]byte, 100), Block: 10}) + 1)
+	src, err = compact.NewChangesetIterator(dir)
+	require.NoError(t, err)
+	_, err = drainChangesets(compact.NewMaterializedIterator(src, compact.MaterializeOptions{MaxBytes: maxBytes}))
+	require.ErrorIs(t, err, compact.ErrChangesetTooLarge)
+
+	src, err = compact.NewChangesetIterator(dir)
+	require.NoError(t, err)
+	changesets, err := drainChangesets(compact.NewMaterializedIterator(src, compact.MaterializeOptions{MaxBytes: maxBytes, Split: true}))
+	require.NoError(t, err)
+	counts := map[int64]int{}
+	for _, cs := range changesets {
+		require.LessOrEqual(t, len(cs.Nodes), 4)
+		counts[cs.Version] += len(cs.Nodes)
+	}
+	for block := int64(1); block <= 10; block++ {
+		require.Equal(t, int(block), counts[block])
+	}
+	// block 10 is split 4, 4, 2
+	last := changesets[len(changesets)-3:]
+	require.True(t, last[0].Partial)
+	require.True(t, last[1].Partial)
+	require.False(t, last[2].Partial)
+
+	// sources reusing their nodes would leave every node of a changeset pointing at the last one read
+	pool := &sync.Pool{New: func() any { return &api.Node{} }}
+	for _, opt := range []compact.IteratorOption{compact.WithReuseNode(), compact.WithNodePool(pool)} {
+		src, err = compact.NewChangesetIteratorWithOptions(dir, opt)
+		require.NoError(t, err)
+		_, err = compact.NewMaterializedIterator(src, compact.MaterializeOptions{})
+		require.Error(t, err)
+	}
+}
+
+func drainChangesets(itr *compact.MaterializedIterator, err error) ([]*api.Changeset, error) {
+	if err != nil {
+		return nil, err
+	}
+	var changesets []*api.Changeset
+	for ; itr.Valid(); err = itr.Next() {
+		if err != nil {
+			return nil, err
+		}
+		changesets = append(changesets, itr.Changeset)
+	}
+	return changesets, err
+}
+
+func Test_Filters(t *testing.T) {
+	storeKeys := []string{"acc", "bank", "staking"}
+	dir := t.TempDir()
+	ctx := compact.StreamingContext{
+		OutDir:       dir,
+		MaxFileSize:  1024 * 1024,
+		OrderedInput: true,
+		In:           make(chan compact.Sequenced),
+	}
+	var nodes []*api.Node
+	for i := 0; i < 600; i++ {
+		nodes = append(nodes, &api.Node{
+			Key:      []byte{byte(i % 4), byte(i)},
+			Block:    int64(i/10) + 1,
+			StoreKey: storeKeys[i%3],
+			Delete:   i%5 == 0,
+		})
+	}
+	go func() {
+		for _, node := range nodes {
+			ctx.In <- node
+		}
+		close(ctx.In)
+	}()
+	_, err := ctx.Compact()
+	require.NoError(t, err)
+
+	cases := []struct {
+		name  string
+		opts  []compact.IteratorOption
+		match func(*api.Node) bool
+	}{
+		{"store keys", []compact.IteratorOption{compact.WithStoreKeys("bank", "staking")},
+			func(n *api.Node) bool { return n.StoreKey != "acc" }},
+		{"without store keys", []compact.IteratorOption{compact.WithoutStoreKeys("bank")},
+			func(n *api.Node) bool { return n.StoreKey != "bank" }},
+		{"key prefixes", []compact.IteratorOption{compact.WithKeyPrefixes([]byte{1}, []byte{2})},
+			func(n *api.Node) bool { return n.Key[0] == 1 || n.Key[0] == 2 }},
+		{"sets", []compact.IteratorOption{compact.WithSetsOnly()},
+			func(n *api.Node) bool { return !n.Delete }},
+		{"bank deletes", []compact.IteratorOption{compact.WithStoreKeys("bank"), compact.WithDeletesOnly()},
+			func(n *api.Node) bool { return n.StoreKey == "bank" && n.Delete }},
+		{"keyed", []compact.IteratorOption{compact.WithStoreKey("gov"), compact.WithStoreKeys("gov")},
+			func(n *api.Node) bool { return true }},
+		{"keyed and excluded", []compact.IteratorOption{compact.WithStoreKey("gov"), compact.WithStoreKeys("bank")},
+			func(n *api.Node) bool { return false }},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var expected []*api.Node
+			for _, node := range nodes {
+				if tc.match(node) {
+					expected = append(expected, node)
+				}
+			}
+			itr, err := ctx.NewIterator(dir, tc.opts...)
+			require.NoError(t, err)
+			i := 0
+			for ; itr.Valid(); err = itr.Next() {
+				require.NoError(t, err)
+				require.Less(t, i, len(expected))
+				require.Equal(t, expected[i].Key, itr.Node.Key)
+				i++
+			}
+			require.Equal(t, len(expected), i)
+		})
+	}
+}
+
+func Test_FillAndCheckHash(t *testing.T) {
+	dir := t.TempDir()
+	ctx := compact.StreamingContext{
+		OutDir:       dir,
+		MaxFileSize:  1024 * 1024,
+		OrderedInput: true,
+		FillHash:     true,
+		In:           make(chan compact.Sequenced),
+	}
+	go func() {
+		for i := 0; i < 100; i++ {
+			ctx.In <- &api.Node{Key: []byte{byte(i)}, Value: []byte{byte(i)}, Block: int64(i) + 1, Delete: i%10 == 0}
+		}
+		// a corrupt hash
+		ctx.In <- &api.Node{Key: []byte{1}, Value: []byte{1}, Block: 101, Hash: []byte{1, 2, 3}}
+		close(ctx.In)
+	}()
+	_, err := ctx.Compact()
+	require.NoError(t, err)
+
+	itr, err := ctx.NewIterator(dir, compact.WithHashCheck())
+	require.NoError(t, err)
+	cnt := 0
+	for ; itr.Valid() && err == nil; err = itr.Next() {
+		require.Equal(t, itr.Node.Delete, len(itr.Node.Hash) == 0)
+		cnt++
+	}
+	require.ErrorIs(t, err, api.ErrHashMismatch)
+	require.Equal(t, 100, cnt)
+}
+
+func Test_Annotate(t *testing.T) {
+	dir := t.TempDir()
+	ctx := compact.StreamingContext{
+		OutDir:       dir,
+		MaxFileSize:  1024,
+		OrderedInput: true,
+		In:           make(chan compact.Sequenced),
+	}
+	writes := []*api.Node{
+		{Key: []byte("a"), Value: []byte("1"), Block: 1},
+		{Key: []byte("b"), Value: []byte("1"), Block: 1},
+		{Key: []byte("a"), Value: []byte("2"), Block: 3},
+		{Key: []byte("b"), Delete: true, Block: 4},
+		{Key: []byte("c"), Value: []byte("1"), Block: 4},
+		{Key: []byte("c"), Value: []byte("2"), Block: 4},
+		{Key: []byte("b"), Value: []byte("2"), Block: 6},
+	}
+	go func() {
+		for _, node := range writes {
+			ctx.In <- node
+		}
+		close(ctx.In)
+	}()
+	_, err := ctx.Compact()
+	require.NoError(t, err)
+
+	outDir := t.TempDir()
+	stats, err := compact.Annotate(dir, &compact.StreamingContext{
+		OutDir:       outDir,
+		MaxFileSize:  1024,
+		OrderedInput: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(6), stats.Writes)
+	require.Equal(t, int64(1), stats.Deletes)
+	require.Equal(t, int64(3), stats.Orphaned)
+	require.NotEmpty(t, stats.Files)
+
+	itr, err := compact.NewSequencedIterator(outDir, func() *api.Node { return &api.Node{} })
+	require.NoError(t, err)
+	var versions [][2]int64
+	var orphaned int
+	for ; itr.Valid(); err = itr.Next() {
+		require.NoError(t, err)
+		versions = append(versions, [2]int64{itr.Node.FirstVersion, itr.Node.LastVersion})
+		if itr.Node.IsOrphaned() {
+			orphaned++
+		}
+	}
+	require.NoError(t, err)
+	require.Equal(t, [][2]int64{{1, 3}, {1, 4}, {3, 0}, {0, 0}, {4, 4}, {4, 0}, {6, 0}}, versions)
+	require.Equal(t, 3, orphaned)
+}
+
+func Test_AnnotateRemovesPartialOutput(t *testing.T) {
+	dir := t.TempDir()
+	ctx := compact.StreamingContext{
+		OutDir:       dir,
+		MaxFileSize:  1024 * 1024,
+		OrderedInput: true,
+		In:           make(chan compact.Sequenced),
+	}
+	values := randomValues(t, 2_000, 100)
+	go func() {
+		for i, v := range values {
+			ctx.In <- &api.Node{Key: []byte{byte(i)}, Value: v, Block: int64(i + 1)}
+		}
+		close(ctx.In)
+	}()
+	_, err := ctx.Compact()
+	require.NoError(t, err)
+
+	annotate := func(outDir string) (*compact.AnnotateStats, error) {
+		return compact.Annotate(dir, &compact.StreamingContext{
+			OutDir:       outDir,
+			MaxFileSize:  16 * 1024,
+			OrderedInput: true,
+		})
+	}
+	stats, err := annotate(t.TempDir())
+	require.NoError(t, err)
+	require.Greater(t, len(stats.Files), 2)
+
+	// occupy both names of the second segment so that writing it fails after the first was written
+	outDir := t.TempDir()
+	second := strings.TrimSuffix(filepath.Base(stats.Files[1]), ".pb.gz")
+	occupied := []string{second + ".pb.gz", fmt.Sprintf("%s-%08d.pb.gz", second, 1)}
+	for _, name := range occupied {
+		require.NoError(t, os.WriteFile(filepath.Join(outDir, name), nil, 0644))
+	}
+	_, err = annotate(outDir)
+	require.ErrorContains(t, err, "already exists")
+
+	entries, err := os.ReadDir(outDir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	require.ElementsMatch(t, occupied, names)
+}
+
+func Test_Envelopes(t *testing.T) {
+	dir := t.TempDir()
+	ctx := compact.StreamingContext{
+		OutDir:       dir,
+		MaxFileSize:  1024 * 1024,
+		OrderedInput: true,
+		FillHash:     true,
+		In:           make(chan compact.Sequenced),
+	}
+	storeKeys := []string{"acc", "bank"}
+	go func() {
+		for block := int64(1); block <= 20; block++ {
+			for i := 0; i < 4; i++ {
+				node := &api.Node{Key: []byte{byte(i), byte(block)}, Value: []byte{byte(i)}, Block: block,
+					StoreKey: storeKeys[i%2]}
+				if i == 3 {
+					ctx.In <- api.DecodeErrorEnvelope(&api.DecodeError{Node: node, StoreKey: node.StoreKey, Reason: "bad"})
+				} else {
+					ctx.In <- api.NodeEnvelope(node)
+				}
+			}
+			ctx.In <- api.BlockMetadataEnvelope(&api.BlockMetadata{Block: block, ChainId: "test", AppHash: []byte{1}})
+		}
+		// a record of a type this reader does not know
+		ctx.In <- &api.Envelope{Block: 21}
+		close(ctx.In)
+	}()
+	_, err := ctx.Compact()
+	require.NoError(t, err)
+
+	var (
+		nodes  []*api.Node
+		errs   []*api.DecodeError
+		blocks []int64
+	)
+	h := compact.EnvelopeHandler{
+		Node: func(n *api.Node) error {
+			nodes = append(nodes, n)
+			return nil
+		},
+		DecodeError: func(e *api.DecodeError) error {
+			errs = append(errs, e)
+			return nil
+		},
+		BlockMetadata: func(m *api.BlockMetadata) error {
+			require.Equal(t, "test", m.ChainId)
+			blocks = append(blocks, m.Block)
+			return nil
+		},
+	}
+	envStats, err := compact.ReadEnvelopes(dir, h, compact.WithHashCheck())
+	require.NoError(t, err)
+	require.Equal(t, compact.EnvelopeStats{Nodes: 60, DecodeErrors: 20, BlockMetadata: 20, Unknown: 1}, envStats)
+	require.Len(t, nodes, 60)
+	require.Len(t, errs, 20)
+	require.Len(t, blocks, 20)
+	for i, n := range nodes {
+		require.Equal(t, int64(i/3+1), n.Block)
+		require.NotEmpty(t, n.Hash)
+	}
+	require.Equal(t, "bank", errs[0].Node.StoreKey)
+
+	// filters apply to nodes and decode errors, and let block metadata through
+	nodes, errs, blocks = nil, nil, nil
+	envStats, err = compact.ReadEnvelopes(dir, h, compact.WithStoreKeys("bank"))
+	require.NoError(t, err)
+	require.Equal(t, compact.EnvelopeStats{Nodes: 20, DecodeErrors: 20, BlockMetadata: 20, Unknown: 1}, envStats)
+	for _, n := range nodes {
+		require.Equal(t, "bank", n.StoreKey)
+	}
+
+	itr, err := compact.NewEnvelopeIterator(dir)
+	require.NoError(t, err)
+	nodeItr, err := compact.EnvelopeNodes(itr)
+	require.NoError(t, err)
+	cnt := 0
+	for ; nodeItr.Valid(); err = nodeItr.Next() {
+		require.NoError(t, err)
+		require.NotNil(t, nodeItr.GetNode())
+		cnt++
+	}
+	require.NoError(t, err)
+	require.Equal(t, 60, cnt)
 }
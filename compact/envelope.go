@@ -0,0 +1,122 @@
+package compact
+
+import (
+	api "github.com/kocubinski/costor-api"
+)
+
+// EnvelopeHandler receives the records of a mixed stream of Envelopes by type.  Records whose handler is nil are
+// skipped.
+type EnvelopeHandler struct {
+	Node          func(*api.Node) error
+	DecodeError   func(*api.DecodeError) error
+	BlockMetadata func(*api.BlockMetadata) error
+	// Unknown receives envelopes which hold none of the above, such as records of a type added to Envelope after this
+	// reader was built.
+	Unknown func(*api.Envelope) error
+}
+
+// EnvelopeStats counts the envelopes Dispatch read by the type of record they held.
+type EnvelopeStats struct {
+	Nodes         int64
+	DecodeErrors  int64
+	BlockMetadata int64
+	Unknown       int64
+}
+
+// NewEnvelopeIterator iterates over the envelopes of the mixed stream in dir.  Store key and key filters apply to the
+// nodes and decode errors of the stream; block metadata always passes them.
+func NewEnvelopeIterator(dir string, opts ...IteratorOption) (*SequencedIterator[*api.Envelope], error) {
+	return NewSequencedIterator(dir, func() *api.Envelope { return &api.Envelope{} }, opts...)
+}
+
+// Dispatch passes the record of every envelope of itr to the handler for its type, and stops at the first error a
+// handler returns.  With WithReuseNode or WithNodePool records are only valid until the handler returns.
+func (h EnvelopeHandler) Dispatch(itr *SequencedIterator[*api.Envelope]) (EnvelopeStats, error) {
+	var (
+		stats EnvelopeStats
+		err   error
+	)
+	for ; itr.Valid(); err = itr.Next() {
+		if err != nil {
+			return stats, err
+		}
+		if err := h.handle(itr.Node, &stats); err != nil {
+			return stats, err
+		}
+	}
+	return stats, err
+}
+
+func (h EnvelopeHandler) handle(e *api.Envelope, stats *EnvelopeStats) error {
+	switch r := e.Record.(type) {
+	case *api.Envelope_Node:
+		stats.Nodes++
+		if h.Node != nil {
+			return h.Node(r.Node)
+		}
+	case *api.Envelope_DecodeError:
+		stats.DecodeErrors++
+		if h.DecodeError != nil {
+			return h.DecodeError(r.DecodeError)
+		}
+	case *api.Envelope_BlockMetadata:
+		stats.BlockMetadata++
+		if h.BlockMetadata != nil {
+			return h.BlockMetadata(r.BlockMetadata)
+		}
+	default:
+		stats.Unknown++
+		if h.Unknown != nil {
+			return h.Unknown(e)
+		}
+	}
+	return nil
+}
+
+// ReadEnvelopes dispatches every envelope of the mixed stream in dir to h.
+func ReadEnvelopes(dir string, h EnvelopeHandler, opts ...IteratorOption) (EnvelopeStats, error) {
+	itr, err := NewEnvelopeIterator(dir, opts...)
+	if err != nil {
+		return EnvelopeStats{}, err
+	}
+	return h.Dispatch(itr)
+}
+
+// EnvelopeNodes adapts an envelope iterator to an api.NodeIterator over the nodes of the stream, skipping its other
+// records, so that a mixed stream can be replayed like a node stream.
+func EnvelopeNodes(itr *SequencedIterator[*api.Envelope]) (api.NodeIterator, error) {
+	it := &envelopeNodeIterator{itr: itr}
+	return it, it.skip()
+}
+
+type envelopeNodeIterator struct {
+	itr *SequencedIterator[*api.Envelope]
+}
+
+// skip advances the underlying iterator to the next envelope holding a node.
+func (it *envelopeNodeIterator) skip() error {
+	for it.itr.Valid() && it.itr.Node.GetNode() == nil {
+		if err := it.itr.Next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (it *envelopeNodeIterator) Next() error {
+	if err := it.itr.Next(); err != nil {
+		return err
+	}
+	return it.skip()
+}
+
+func (it *envelopeNodeIterator) Valid() bool {
+	return it.itr.Valid()
+}
+
+func (it *envelopeNodeIterator) GetNode() *api.Node {
+	if !it.itr.Valid() {
+		return nil
+	}
+	return it.itr.Node.GetNode()
+}
@@ -0,0 +1,312 @@
+package compact
+
+import (
+	"bytes"
+
+	api "github.com/kocubinski/costor-api"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// WithStoreKey sets StoreKey on every record read, overriding the value stored in the segment.  This is how records
+// in a per-store directory of a multistore, which are written without a store key, are keyed.
+func WithStoreKey(storeKey string) IteratorOption {
+	return func(o *iteratorOptions) {
+		o.storeKey = storeKey
+	}
+}
+
+// WithStoreKeys only yields records with one of the given store keys.
+func WithStoreKeys(storeKeys ...string) IteratorOption {
+	return func(o *iteratorOptions) {
+		f := o.recordFilter()
+		if f.include == nil {
+			f.include = make(map[string]struct{})
+		}
+		for _, sk := range storeKeys {
+			f.include[sk] = struct{}{}
+		}
+	}
+}
+
+// WithoutStoreKeys skips records with any of the given store keys.
+func WithoutStoreKeys(storeKeys ...string) IteratorOption {
+	return func(o *iteratorOptions) {
+		f := o.recordFilter()
+		if f.exclude == nil {
+			f.exclude = make(map[string]struct{})
+		}
+		for _, sk := range storeKeys {
+			f.exclude[sk] = struct{}{}
+		}
+	}
+}
+
+// WithKeyPrefixes only yields records whose key starts with one of the given prefixes.
+func WithKeyPrefixes(prefixes ...[]byte) IteratorOption {
+	return func(o *iteratorOptions) {
+		f := o.recordFilter()
+		f.prefixes = append(f.prefixes, prefixes...)
+	}
+}
+
+// WithSetsOnly skips delete records.
+func WithSetsOnly() IteratorOption {
+	return func(o *iteratorOptions) {
+		o.recordFilter().op = opSet
+	}
+}
+
+// WithDeletesOnly skips set records.
+func WithDeletesOnly() IteratorOption {
+	return func(o *iteratorOptions) {
+		o.recordFilter().op = opDelete
+	}
+}
+
+type operation int
+
+const (
+	opAny operation = iota
+	opSet
+	opDelete
+)
+
+// filter selects records by store key, key prefix and operation.  It applies to Node and DecodeError records, which
+// are matched on their node, and to the Envelopes holding them; records of other types, including the block metadata
+// of an Envelope, are never filtered.
+type filter struct {
+	include  map[string]struct{}
+	exclude  map[string]struct{}
+	prefixes [][]byte
+	op       operation
+}
+
+func (o *iteratorOptions) recordFilter() *filter {
+	if o.filter == nil {
+		o.filter = &filter{}
+	}
+	return o.filter
+}
+
+// allowsStoreKey returns false if no record with storeKey can match.
+func (f *filter) allowsStoreKey(storeKey string) bool {
+	if f.include != nil {
+		if _, ok := f.include[storeKey]; !ok {
+			return false
+		}
+	}
+	_, excluded := f.exclude[storeKey]
+	return !excluded
+}
+
+// allowsStoreKeyBytes is allowsStoreKey without converting storeKey to a string.
+func (f *filter) allowsStoreKeyBytes(storeKey []byte) bool {
+	if f.include != nil {
+		if _, ok := f.include[string(storeKey)]; !ok {
+			return false
+		}
+	}
+	_, excluded := f.exclude[string(storeKey)]
+	return !excluded
+}
+
+// match applies the key and operation filters; the store key is checked by the caller.
+func (f *filter) match(key []byte, del bool) bool {
+	switch f.op {
+	case opSet:
+		if del {
+			return false
+		}
+	case opDelete:
+		if !del {
+			return false
+		}
+	}
+	if len(f.prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range f.prefixes {
+		if bytes.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordKind identifies the record types a filter understands.
+type recordKind int
+
+const (
+	otherRecord recordKind = iota
+	nodeRecord
+	decodeErrorRecord
+	envelopeRecord
+)
+
+func kindOf[T Sequenced]() recordKind {
+	var zero T
+	switch any(zero).(type) {
+	case *api.Node:
+		return nodeRecord
+	case *api.DecodeError:
+		return decodeErrorRecord
+	case *api.Envelope:
+		return envelopeRecord
+	}
+	return otherRecord
+}
+
+// Field numbers from node.proto.
+const (
+	nodeKeyField      = 1
+	nodeDeleteField   = 3
+	nodeStoreKeyField = 5
+
+	decodeErrorNodeField     = 1
+	decodeErrorStoreKeyField = 2
+)
+
+// Field numbers from envelope.proto.
+const (
+	envelopeNodeField        = 2
+	envelopeDecodeErrorField = 3
+)
+
+// matchRaw applies f to a marshalled record without unmarshalling it.  storeKey, if set, overrides the record's own.
+// Malformed records match so that unmarshalling reports the error.
+func (f *filter) matchRaw(kind recordKind, bz []byte, storeKey string) bool {
+	var (
+		sk  []byte
+		key []byte
+		del bool
+		ok  bool
+	)
+	if kind == envelopeRecord {
+		kind, bz = scanEnvelope(bz)
+	}
+	switch kind {
+	case nodeRecord:
+		sk, key, del, ok = scanNode(bz)
+	case decodeErrorRecord:
+		var nodeSk []byte
+		sk, nodeSk, key, del, ok = scanDecodeError(bz)
+		if len(sk) == 0 {
+			sk = nodeSk
+		}
+	default:
+		return true
+	}
+	if !ok {
+		return true
+	}
+	if storeKey != "" {
+		// the store key was checked against the filter when the iterator was created
+		return f.match(key, del)
+	}
+	return f.allowsStoreKeyBytes(sk) && f.match(key, del)
+}
+
+func scanNode(bz []byte) (storeKey, key []byte, del bool, ok bool) {
+	for len(bz) > 0 {
+		num, typ, n := protowire.ConsumeTag(bz)
+		if n < 0 {
+			return nil, nil, false, false
+		}
+		bz = bz[n:]
+		switch {
+		case num == nodeKeyField && typ == protowire.BytesType:
+			key, n = protowire.ConsumeBytes(bz)
+		case num == nodeStoreKeyField && typ == protowire.BytesType:
+			storeKey, n = protowire.ConsumeBytes(bz)
+		case num == nodeDeleteField && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(bz)
+			del = v != 0
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, bz)
+		}
+		if n < 0 {
+			return nil, nil, false, false
+		}
+		bz = bz[n:]
+	}
+	return storeKey, key, del, true
+}
+
+func scanDecodeError(bz []byte) (storeKey, nodeStoreKey, key []byte, del bool, ok bool) {
+	for len(bz) > 0 {
+		num, typ, n := protowire.ConsumeTag(bz)
+		if n < 0 {
+			return nil, nil, nil, false, false
+		}
+		bz = bz[n:]
+		switch {
+		case num == decodeErrorNodeField && typ == protowire.BytesType:
+			var v []byte
+			v, n = protowire.ConsumeBytes(bz)
+			if n >= 0 {
+				nodeStoreKey, key, del, ok = scanNode(v)
+				if !ok {
+					return nil, nil, nil, false, false
+				}
+			}
+		case num == decodeErrorStoreKeyField && typ == protowire.BytesType:
+			storeKey, n = protowire.ConsumeBytes(bz)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, bz)
+		}
+		if n < 0 {
+			return nil, nil, nil, false, false
+		}
+		bz = bz[n:]
+	}
+	return storeKey, nodeStoreKey, key, del, true
+}
+
+// scanEnvelope returns the kind and bytes of the record an Envelope holds, or otherRecord if it holds neither a Node
+// nor a DecodeError.
+func scanEnvelope(bz []byte) (recordKind, []byte) {
+	for len(bz) > 0 {
+		num, typ, n := protowire.ConsumeTag(bz)
+		if n < 0 {
+			return otherRecord, nil
+		}
+		bz = bz[n:]
+		if typ == protowire.BytesType && (num == envelopeNodeField || num == envelopeDecodeErrorField) {
+			v, n := protowire.ConsumeBytes(bz)
+			if n < 0 {
+				return otherRecord, nil
+			}
+			if num == envelopeNodeField {
+				return nodeRecord, v
+			}
+			return decodeErrorRecord, v
+		}
+		n = protowire.ConsumeFieldValue(num, typ, bz)
+		if n < 0 {
+			return otherRecord, nil
+		}
+		bz = bz[n:]
+	}
+	return otherRecord, nil
+}
+
+// setStoreKey sets the store key of a Node or DecodeError record.
+func setStoreKey(record any, storeKey string) {
+	switch r := record.(type) {
+	case *api.Node:
+		r.StoreKey = storeKey
+	case *api.DecodeError:
+		r.StoreKey = storeKey
+		if r.Node != nil {
+			r.Node.StoreKey = storeKey
+		}
+	case *api.Envelope:
+		switch rec := r.Record.(type) {
+		case *api.Envelope_Node:
+			setStoreKey(rec.Node, storeKey)
+		case *api.Envelope_DecodeError:
+			setStoreKey(rec.DecodeError, storeKey)
+		}
+	}
+}
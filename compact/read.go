@@ -1,12 +1,17 @@
 package compact
 
 import (
+	"bufio"
 	"compress/gzip"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	api "github.com/kocubinski/costor-api"
 	"github.com/kocubinski/costor-api/logz"
@@ -16,31 +21,165 @@ import (
 
 var log = logz.Logger.With().Str("module", "compact").Logger()
 
+// openSegment opens a segment for reading.  Tests replace it to observe which segments are left open.
+var openSegment = func(path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
+
+// readBufferSize is the size of the buffered readers on either side of the decompressor.
+const readBufferSize = 64 * 1024
+
+// IteratorOption configures a SequencedIterator.
+type IteratorOption func(*iteratorOptions)
+
+type iteratorOptions struct {
+	reuseNode bool
+	pool      *sync.Pool
+	recover   bool
+	skipAhead bool
+	follow    context.Context
+	poll      time.Duration
+	dir       string
+	storeKey  string
+	filter    *filter
+	checkHash bool
+}
+
+// WithReuseNode unmarshals every record into the same node instance.  Node is only valid until the next call to
+// Next, so callers must copy anything they need to keep.
+func WithReuseNode() IteratorOption {
+	return func(o *iteratorOptions) {
+		o.reuseNode = true
+	}
+}
+
+// WithNodePool draws nodes from pool and puts the previous node back into it on every call to Next.  Like
+// WithReuseNode, Node is only valid until the next call to Next.  A pool may be shared by several iterators over the
+// same record type.
+func WithNodePool(pool *sync.Pool) IteratorOption {
+	return func(o *iteratorOptions) {
+		o.pool = pool
+	}
+}
+
+// WithRecovery puts the iterator in recovery mode.  A segment which ends part way through a record, or which cannot be
+// decompressed or unmarshalled past some point, no longer fails iteration.  Every complete record before the damage is
+// yielded and the damage is recorded as a *TruncationError in Truncations.  If skipAhead is true iteration continues
+// with the next segment, otherwise it stops and Next returns the *TruncationError.
+func WithRecovery(skipAhead bool) IteratorOption {
+	return func(o *iteratorOptions) {
+		o.recover = true
+		o.skipAhead = skipAhead
+	}
+}
+
+// WithHashCheck verifies the Hash of every node read, including the node of a DecodeError or Envelope, against its IAVL
+// leaf hash.
+// Next returns an error wrapping api.ErrHashMismatch on the first mismatch.  Nodes without a Hash are not checked.
+func WithHashCheck() IteratorOption {
+	return func(o *iteratorOptions) {
+		o.checkHash = true
+	}
+}
+
+// WithFollow keeps a directory iterator running after its last segment, like tail -f.  The directory is listed every
+// pollInterval and segments which have appeared since are read in order.  Next blocks until a record is available;
+// once ctx is done iteration ends and Next returns ctx.Err().  Only finished segments are read, StreamingContext.Compact
+// writes each segment under a temporary name and renames it into place when complete.
+func WithFollow(ctx context.Context, pollInterval time.Duration) IteratorOption {
+	return func(o *iteratorOptions) {
+		o.follow = ctx
+		o.poll = pollInterval
+	}
+}
+
+// TruncationError describes where a segment stopped being readable.
+type TruncationError struct {
+	// File is the path of the damaged segment.
+	File string
+	// Record is the index within File of the first record that could not be read, which is also the number of
+	// records recovered from it.
+	Record int
+	// Offset is the uncompressed byte offset of that record.
+	Offset int64
+	Err    error
+}
+
+func (e *TruncationError) Error() string {
+	return fmt.Sprintf("%s truncated at record %d (offset %d): %v", e.File, e.Record, e.Offset, e.Err)
+}
+
+func (e *TruncationError) Unwrap() error {
+	return e.Err
+}
+
 type SequencedIterator[T Sequenced] struct {
 	Node T
 	Err  error
+	// Truncations lists the damaged segments encountered so far in recovery mode.
+	Truncations []*TruncationError
 
 	valid     bool
 	newNodeFn func() T
+	kind      recordKind
+	opts      iteratorOptions
+	unmarshal proto.UnmarshalOptions
 	log       zerolog.Logger
-	nextFile  chan string
-	file      *os.File
+	seen      map[string]struct{}
+	files     []string
+	fileName  string
+	file      io.ReadCloser
+	src       *bufio.Reader
 	zr        *gzip.Reader
+	br        *bufio.Reader
+	lengthBz  [4]byte
+	nodeBz    []byte
+	record    int
 	// debug
 	idx        int
 	totalNodes int
 	totalBytes int64
 }
 
-func NewSequencedIterator[T Sequenced](dir string, newNode func() T) (*SequencedIterator[T], error) {
-	ch, err := newIteratorChannel(dir)
+func NewSequencedIterator[T Sequenced](dir string, newNode func() T, opts ...IteratorOption) (*SequencedIterator[T], error) {
+	files, err := listSegments(dir)
 	if err != nil {
 		return nil, err
 	}
+	return newSequencedIterator(dir, files, newNode, append(opts, withDir(dir))...)
+}
+
+// withDir records the directory being iterated, which is re-listed in follow mode.
+func withDir(dir string) IteratorOption {
+	return func(o *iteratorOptions) {
+		o.dir = dir
+	}
+}
+
+// NewSegmentIterator iterates over the records of the single segment file at path.
+func NewSegmentIterator[T Sequenced](path string, newNode func() T, opts ...IteratorOption) (*SequencedIterator[T], error) {
+	return newSequencedIterator(path, []string{path}, newNode, opts...)
+}
+
+func newSequencedIterator[T Sequenced](
+	path string, files []string, newNode func() T, opts ...IteratorOption,
+) (*SequencedIterator[T], error) {
 	itr := &SequencedIterator[T]{
-		nextFile:  ch,
-		log:       log.With().Str("path", dir).Logger(),
+		files:     files,
+		log:       log.With().Str("path", path).Logger(),
 		newNodeFn: newNode,
+		kind:      kindOf[T](),
+	}
+	for _, opt := range opts {
+		opt(&itr.opts)
+	}
+	if f := itr.opts.filter; f != nil && itr.opts.storeKey != "" && !f.allowsStoreKey(itr.opts.storeKey) {
+		// every record will be keyed with a filtered store key, so there is nothing to read
+		itr.files = nil
+		itr.opts.follow = nil
+	}
+	if itr.opts.follow != nil {
+		itr.seen = make(map[string]struct{})
 	}
 	return itr, itr.Next()
 }
@@ -53,272 +192,251 @@ func (it *SequencedIterator[T]) Valid() bool {
 	return it.valid
 }
 
-func (it *SequencedIterator[T]) Next() error {
-	var err error
-
-	if it.file == nil {
-		nextFile, ok := <-it.nextFile
-		// end of iteration
-		if !ok {
-			it.valid = false
-			return nil
-		}
-		it.log.Info().Msgf("open file: %s", filepath.Base(nextFile))
-		it.file, err = os.Open(nextFile)
-		it.zr, err = gzip.NewReader(it.file)
-		if err != nil {
-			return err
-		}
-	}
+// File returns the path of the segment the current record was read from.
+func (it *SequencedIterator[T]) File() string {
+	return it.fileName
+}
 
-	lengthBz := make([]byte, 4)
-	err = binary.Read(it.zr, binary.LittleEndian, &lengthBz)
+func (it *SequencedIterator[T]) openFile(path string) error {
+	it.log.Info().Msgf("open file: %s", filepath.Base(path))
+	f, err := openSegment(path)
 	if err != nil {
-		if err == io.EOF {
-			if err := it.file.Close(); err != nil {
-				return err
-			}
-			it.file = nil
-			it.idx = 0
-			return it.Next()
-		}
 		return err
 	}
-	it.totalBytes += 4
-	it.idx += 4
-	length := int(binary.LittleEndian.Uint32(lengthBz))
-
-	nbz := make([]byte, length)
-	n, err := it.zr.Read(nbz)
-	if err != nil && err != io.EOF {
-		return err
+	it.fileName = path
+	it.file = f
+	if it.src == nil {
+		it.src = bufio.NewReaderSize(f, readBufferSize)
+	} else {
+		it.src.Reset(f)
 	}
-	// fill rest of the bytes if the decompressor returned less than length
-	if n != length {
-		rest := length - n
-		for {
-			bz := make([]byte, rest)
-			m, err := it.zr.Read(bz)
-			if err != nil && err != io.EOF {
-				return err
-			}
-			if m == rest {
-				copy(nbz[length-rest:], bz)
-				break
-			}
-			if m == 0 {
-				return fmt.Errorf("read 0 bytes, needed %d", rest)
-			}
-			copy(nbz[length-rest:], bz[:m])
-			rest -= m
-		}
+	if it.zr == nil {
+		it.zr, err = gzip.NewReader(it.src)
+	} else {
+		err = it.zr.Reset(it.src)
 	}
-
-	it.totalNodes++
-	node := it.newNodeFn()
-	if err := proto.Unmarshal(nbz, node); err != nil {
+	if err != nil {
 		return err
 	}
-	it.totalBytes += int64(length)
-	it.idx += length
-	it.Node = node
-	it.valid = true
+	if it.br == nil {
+		it.br = bufio.NewReaderSize(it.zr, readBufferSize)
+	} else {
+		it.br.Reset(it.zr)
+	}
 	return nil
 }
 
-func (c *StreamingContext) NewIterator(dir string) (*SequencedIterator[*api.Node], error) {
-	return NewSequencedIterator[*api.Node](dir, func() *api.Node { return &api.Node{} })
+func (it *SequencedIterator[T]) closeFile() error {
+	err := it.file.Close()
+	it.file = nil
+	it.idx = 0
+	it.record = 0
+	return err
 }
 
-// newIteratorChannel returns a channel that enumerates over all files in a directory.
-// the go thread parks between each file read, so it's not very efficient.
-func newIteratorChannel(dir string) (chan string, error) {
-	ch := make(chan string)
-	go func() {
-		err := filepath.WalkDir(dir, func(path string, info os.DirEntry, readErr error) error {
-			if readErr != nil {
-				return readErr
-			}
-			if info.IsDir() {
-				return nil
-			}
-			ch <- fmt.Sprintf("%s/%s", dir, info.Name())
-			return nil
-		})
-		if err != nil {
-			panic(err)
+// truncated handles err, raised while reading the record at the current position.  Outside of recovery mode the file
+// is closed, iteration stops and err is returned as is.  In recovery mode the damage is recorded, the file closed, and
+// either nil is returned to continue with the next segment or the *TruncationError to stop.
+func (it *SequencedIterator[T]) truncated(err error) error {
+	if !it.opts.recover {
+		if cerr := it.closeFile(); cerr != nil {
+			it.log.Warn().Err(cerr).Msgf("close %s", filepath.Base(it.fileName))
 		}
-		close(ch)
-	}()
-	return ch, nil
-}
-
-var _ api.NodeIterator = (*StoreKeyedIterator)(nil)
-
-// StoreKeyedIterator iterates over all nodes in a directory, setting each node's StoreKey to the given value.
-type StoreKeyedIterator struct {
-	// if set, set each node's StoreKey to this
-	StoreKey string
-
-	itr     *SequencedIterator[*api.Node]
-	paused  bool
-	version int64
-}
-
-func (s *StoreKeyedIterator) Next() error {
-	if s.paused {
-		return nil
-	}
-	if err := s.itr.Next(); err != nil {
+		it.files = nil
+		it.valid = false
 		return err
 	}
-
-	if s.itr.Node != nil && s.StoreKey != "" {
-		s.itr.Node.StoreKey = s.StoreKey
-	}
-	if s.itr.Node.Block > s.version {
-		s.paused = true
-	}
-
-	return nil
-}
-
-func (s *StoreKeyedIterator) Valid() bool {
-	if s == nil {
-		return false
-	}
-	if s.itr == nil {
-		return false
+	terr := &TruncationError{File: it.fileName, Record: it.record, Offset: int64(it.idx), Err: err}
+	it.log.Warn().Err(err).Msgf("%s truncated at record %d", filepath.Base(it.fileName), it.record)
+	it.Truncations = append(it.Truncations, terr)
+	if err := it.closeFile(); err != nil {
+		return err
 	}
-	return !s.paused && s.itr.Valid()
-}
-
-func (s *StoreKeyedIterator) GetNode() *api.Node {
-	if s.paused {
+	if it.opts.skipAhead {
 		return nil
 	}
-	return s.itr.Node
+	it.files = nil
+	it.valid = false
+	return terr
 }
 
-type ChangesetIterator struct {
-	nodes    *StoreKeyedIterator
-	version  int64
-	nextNode *api.Node
-}
-
-func NewChangesetIterator(dir string, storeKey ...string) (*ChangesetIterator, error) {
-	streamCtx := &StreamingContext{}
-	itr, err := streamCtx.NewIterator(dir)
-	if err != nil {
-		return nil, err
-	}
-	skItr := &StoreKeyedIterator{itr: itr, paused: true}
-	if len(storeKey) > 0 {
-		skItr.StoreKey = storeKey[0]
-	}
-	changeItr := &ChangesetIterator{
-		nodes: skItr,
-	}
-	err = changeItr.Next()
-	if err != nil {
-		return nil, err
+// nextNode returns the node the next record is unmarshalled into.
+func (it *SequencedIterator[T]) nextNode() T {
+	switch {
+	case it.opts.reuseNode:
+		if it.valid {
+			return it.Node
+		}
+	case it.opts.pool != nil:
+		if n, ok := it.opts.pool.Get().(T); ok {
+			return n
+		}
 	}
-	return changeItr, nil
+	return it.newNodeFn()
 }
 
-func (it *ChangesetIterator) Next() error {
-	if !it.nodes.Valid() && !it.nodes.paused {
-		it.nodes = nil
-		return nil
-	}
-	if it.nodes.paused {
-		it.nodes.paused = false
-		it.nodes.version = it.nodes.GetNode().Block
-	} else {
-		return fmt.Errorf("expected paused iterator")
+// waitForSegments polls the iterated directory until it holds segments which have not been read yet, or the follow
+// context is done.
+func (it *SequencedIterator[T]) waitForSegments() error {
+	ticker := time.NewTicker(it.opts.poll)
+	defer ticker.Stop()
+	for {
+		files, err := listSegments(it.opts.dir)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if _, ok := it.seen[file]; !ok {
+				it.files = append(it.files, file)
+			}
+		}
+		if len(it.files) > 0 {
+			return nil
+		}
+		select {
+		case <-it.opts.follow.Done():
+			return it.opts.follow.Err()
+		case <-ticker.C:
+		}
 	}
-	return nil
 }
 
-func (it *ChangesetIterator) Valid() bool {
-	return it.nodes.Valid()
-}
+func (it *SequencedIterator[T]) Next() error {
+	if it.opts.pool != nil && it.valid {
+		it.opts.pool.Put(it.Node)
+		it.valid = false
+	}
+	for {
+		if it.file == nil {
+			if len(it.files) == 0 && it.opts.follow != nil && it.opts.dir != "" {
+				if err := it.waitForSegments(); err != nil {
+					it.valid = false
+					return err
+				}
+			}
+			// end of iteration
+			if len(it.files) == 0 {
+				it.valid = false
+				return nil
+			}
+			nextFile := it.files[0]
+			it.files = it.files[1:]
+			if it.seen != nil {
+				it.seen[nextFile] = struct{}{}
+			}
+			if err := it.openFile(nextFile); err != nil {
+				if it.file == nil {
+					return err
+				}
+				if err := it.truncated(err); err != nil {
+					return err
+				}
+				continue
+			}
+		}
 
-func (it *ChangesetIterator) Nodes() api.NodeIterator {
-	return it.nodes
-}
+		_, err := io.ReadFull(it.br, it.lengthBz[:])
+		if err == io.EOF {
+			if err := it.closeFile(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			if err := it.truncated(err); err != nil {
+				return err
+			}
+			continue
+		}
+		length := int(binary.LittleEndian.Uint32(it.lengthBz[:]))
 
-func (it *ChangesetIterator) Version() int64 {
-	return it.nodes.version
-}
+		if cap(it.nodeBz) < length {
+			it.nodeBz = make([]byte, length)
+		}
+		nbz := it.nodeBz[:length]
+		if _, err := io.ReadFull(it.br, nbz); err != nil {
+			if err := it.truncated(err); err != nil {
+				return err
+			}
+			continue
+		}
 
-type MulitChangesetIterator struct {
-	*api.Changeset
-	iterators []*ChangesetIterator
-}
+		if f := it.opts.filter; f != nil && !f.matchRaw(it.kind, nbz, it.opts.storeKey) {
+			it.totalBytes += int64(length) + 4
+			it.idx += length + 4
+			it.record++
+			continue
+		}
 
-func NewMultiChangesetIterator(dir string) (*MulitChangesetIterator, error) {
-	multiItr := &MulitChangesetIterator{
-		Changeset: &api.Changeset{},
-	}
-	files, err := os.ReadDir(dir)
-	if err != nil {
-		return nil, err
-	}
-	for _, file := range files {
-		if !file.IsDir() {
-			return nil, fmt.Errorf("expected directory, got file: %s", file.Name())
+		node := it.nextNode()
+		// Unmarshal copies bytes fields out of nbz, so the buffer is safe to reuse.
+		if err := it.unmarshal.Unmarshal(nbz, node); err != nil {
+			if err := it.truncated(err); err != nil {
+				return err
+			}
+			continue
 		}
-		itr, err := NewChangesetIterator(fmt.Sprintf("%s/%s", dir, file.Name()), file.Name())
-		if err != nil {
-			return nil, err
+		if it.opts.storeKey != "" {
+			setStoreKey(node, it.opts.storeKey)
 		}
-		multiItr.iterators = append(multiItr.iterators, itr)
-	}
-	err = multiItr.Next()
-	if err != nil {
-		return nil, err
+		if it.opts.checkHash {
+			if err := verifyHash(node); err != nil {
+				return err
+			}
+		}
+		it.totalNodes++
+		it.totalBytes += int64(length) + 4
+		it.idx += length + 4
+		it.record++
+		it.Node = node
+		it.valid = true
+		return nil
 	}
-	return multiItr, nil
-}
-
-func (it *MulitChangesetIterator) Next() error {
-	//it.Changeset = &api.Changeset{Version: math.MaxInt64}
-	//for _, itr := range it.iterators {
-	//	if itr.Valid() {
-	//		if itr.Version < it.Version {
-	//			it.Version = itr.Version
-	//		}
-	//	}
-	//}
-	//for _, itr := range it.iterators {
-	//	if itr.Version == it.Version {
-	//		it.Nodes = append(it.Nodes, itr.Nodes...)
-	//		err := itr.Next()
-	//		if err != nil {
-	//			return err
-	//		}
-	//	}
-	//}
-	//return nil
-	return nil
 }
 
-func (it *MulitChangesetIterator) Valid() bool {
-	for _, itr := range it.iterators {
-		if !itr.Valid() {
-			return false
+func verifyHash(record any) error {
+	switch r := record.(type) {
+	case *api.Node:
+		return r.VerifyHash()
+	case *api.DecodeError:
+		if r.Node != nil {
+			return r.Node.VerifyHash()
+		}
+	case *api.Envelope:
+		switch rec := r.Record.(type) {
+		case *api.Envelope_Node:
+			return verifyHash(rec.Node)
+		case *api.Envelope_DecodeError:
+			return verifyHash(rec.DecodeError)
 		}
 	}
-	return true
+	return nil
 }
 
-func (it *MulitChangesetIterator) Nodes() api.NodeIterator {
-	return nil
-	//return it.Changeset
+func (c *StreamingContext) NewIterator(dir string, opts ...IteratorOption) (*SequencedIterator[*api.Node], error) {
+	return NewSequencedIterator[*api.Node](dir, func() *api.Node { return &api.Node{} }, opts...)
 }
 
-func (it *MulitChangesetIterator) Version() int64 {
-	//return it.Version
-	return 0
+const (
+	// segmentSuffix is the file extension of a finished segment.
+	segmentSuffix = ".pb.gz"
+	// tempSuffix is appended to the name of a segment while it is being written.
+	tempSuffix = ".tmp"
+)
+
+// listSegments returns the paths of all segments in dir in lexical order, which for segments named by block range is
+// also block order.
+func listSegments(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), segmentSuffix) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	return files, nil
 }
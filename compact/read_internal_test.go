@@ -0,0 +1,81 @@
+package compact
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/stretchr/testify/require"
+)
+
+// trackedFile records whether it was closed.
+type trackedFile struct {
+	io.ReadCloser
+	closed bool
+}
+
+func (f *trackedFile) Close() error {
+	f.closed = true
+	return f.ReadCloser.Close()
+}
+
+// trackOpens makes the iterators of the test open segments through trackedFiles, which it returns.
+func trackOpens(t *testing.T) *[]*trackedFile {
+	var opened []*trackedFile
+	open := openSegment
+	openSegment = func(path string) (io.ReadCloser, error) {
+		f, err := open(path)
+		if err != nil {
+			return nil, err
+		}
+		tf := &trackedFile{ReadCloser: f}
+		opened = append(opened, tf)
+		return tf, nil
+	}
+	t.Cleanup(func() { openSegment = open })
+	return &opened
+}
+
+func Test_FailedReadClosesFile(t *testing.T) {
+	dir := t.TempDir()
+	ctx := StreamingContext{
+		OutDir:       dir,
+		MaxFileSize:  1024,
+		OrderedInput: true,
+		In:           make(chan Sequenced),
+	}
+	go func() {
+		for i := 0; i < 100; i++ {
+			ctx.In <- &api.Node{Key: []byte{byte(i)}, Value: []byte("value"), Block: 2}
+		}
+		close(ctx.In)
+	}()
+	stats, err := ctx.Compact()
+	require.NoError(t, err)
+	require.Len(t, stats.FilesWritten, 1)
+	bz, err := os.ReadFile(stats.FilesWritten[0])
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(stats.FilesWritten[0], bz[:len(bz)/2], 0644))
+	// sorts before the truncated segment, so it is the first one read
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000001.pb.gz"), []byte("not gzip"), 0644))
+	newNode := func() *api.Node { return &api.Node{} }
+
+	// a segment with a bad header fails on open
+	opened := trackOpens(t)
+	_, err = NewSequencedIterator(dir, newNode)
+	require.Error(t, err)
+	require.Len(t, *opened, 1)
+	require.True(t, (*opened)[0].closed)
+
+	// a truncated one part way through
+	*opened = nil
+	itr, err := NewSegmentIterator(stats.FilesWritten[0], newNode)
+	for err == nil && itr.Valid() {
+		err = itr.Next()
+	}
+	require.Error(t, err)
+	require.Len(t, *opened, 1)
+	require.True(t, (*opened)[0].closed)
+}
@@ -0,0 +1,258 @@
+package compact
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/core"
+)
+
+// BlockRange is an inclusive range of block heights.
+type BlockRange struct {
+	First int64 `json:"first"`
+	Last  int64 `json:"last"`
+}
+
+func (r BlockRange) String() string {
+	if r.First == r.Last {
+		return fmt.Sprintf("%d", r.First)
+	}
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+// RepairedSegment describes what Repair did with one damaged segment.
+type RepairedSegment struct {
+	File        string `json:"file"`
+	Quarantined string `json:"quarantined"`
+	Truncation  string `json:"truncation"`
+	// Salvaged is the number of records rewritten from File.
+	Salvaged  int      `json:"salvaged"`
+	Rewritten []string `json:"rewritten"`
+	// Lost is the range of blocks which are no longer complete in the dataset.  It is nil for segments written from
+	// unordered input, whose names do not carry a block range.
+	Lost *BlockRange `json:"lost,omitempty"`
+}
+
+type RepairReport struct {
+	Dir      string            `json:"dir"`
+	DryRun   bool              `json:"dry_run"`
+	Segments []RepairedSegment `json:"segments"`
+}
+
+// LostBlocks returns the lost block ranges of all repaired segments, merging ranges which touch.
+func (r *RepairReport) LostBlocks() []BlockRange {
+	var ranges []BlockRange
+	for _, seg := range r.Segments {
+		if seg.Lost == nil {
+			continue
+		}
+		if n := len(ranges); n > 0 && seg.Lost.First <= ranges[n-1].Last+1 {
+			if seg.Lost.Last > ranges[n-1].Last {
+				ranges[n-1].Last = seg.Lost.Last
+			}
+			continue
+		}
+		ranges = append(ranges, *seg.Lost)
+	}
+	return ranges
+}
+
+func (r *RepairReport) Report() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("repair %s:\n", r.Dir))
+	if r.DryRun {
+		sb.WriteString("dry run, nothing was changed\n")
+	}
+	sb.WriteString(fmt.Sprintf("damaged segments: %d\n", len(r.Segments)))
+	for _, seg := range r.Segments {
+		sb.WriteString(fmt.Sprintf("  %s: salvaged %d records (%s)\n", filepath.Base(seg.File), seg.Salvaged, seg.Truncation))
+	}
+	lost := r.LostBlocks()
+	if len(lost) == 0 {
+		return sb.String()
+	}
+	sb.WriteString("lost blocks:\n")
+	for _, br := range lost {
+		sb.WriteString(fmt.Sprintf("  %s\n", br))
+	}
+	return sb.String()
+}
+
+// Repair finds the damaged segments in dir, moves each to quarantineDir and rewrites in its place the records which
+// can still be read.  The last block read from a damaged segment may be incomplete, so its records are dropped along
+// with the rest of the block range named by the segment; that range is reported as lost.  If ctx.DryRun is set the
+// report is produced without touching any files.
+func Repair[T Sequenced](ctx core.Context, dir, quarantineDir string, newNode func() T) (*RepairReport, error) {
+	files, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+	report := &RepairReport{Dir: dir, DryRun: ctx.DryRun}
+	for _, file := range files {
+		seg, cutoff, err := inspectSegment(file, newNode)
+		if err != nil {
+			return nil, err
+		}
+		if seg == nil {
+			continue
+		}
+		if !ctx.DryRun {
+			if err := rewriteSegment(seg, quarantineDir, cutoff, newNode); err != nil {
+				return nil, err
+			}
+		}
+		report.Segments = append(report.Segments, *seg)
+	}
+	return report, nil
+}
+
+// inspectSegment reads file in recovery mode.  If it is damaged the returned segment describes what can be salvaged
+// from it: every record with a sequence below cutoff.
+func inspectSegment[T Sequenced](file string, newNode func() T) (*RepairedSegment, int64, error) {
+	var (
+		records    int
+		firstBlock int64 = math.MaxInt64
+		lastBlock  int64
+	)
+	itr, err := NewSegmentIterator(file, newNode, WithReuseNode(), WithRecovery(false))
+	for ; err == nil && itr.Valid(); err = itr.Next() {
+		records++
+		seq := itr.Node.Sequence()
+		if seq < firstBlock {
+			firstBlock = seq
+		}
+		if seq > lastBlock {
+			lastBlock = seq
+		}
+	}
+	var terr *TruncationError
+	if err == nil {
+		return nil, 0, nil
+	}
+	if !errors.As(err, &terr) {
+		return nil, 0, err
+	}
+
+	seg := &RepairedSegment{File: file, Truncation: terr.Error()}
+	first, last, named := parseSegmentName(file)
+	if !named {
+		seg.Salvaged = records
+		return seg, math.MaxInt64, nil
+	}
+	if isSplitBlock(file, first, last, records > 0 && firstBlock == first && lastBlock == first) {
+		last = first
+	}
+	cutoff := first
+	if records > 0 && lastBlock > first {
+		cutoff = lastBlock
+	}
+	seg.Lost = &BlockRange{First: cutoff, Last: last}
+	// count what will be kept, which excludes the possibly incomplete last block
+	itr, err = NewSegmentIterator(file, newNode, WithReuseNode(), WithRecovery(true))
+	for ; err == nil && itr.Valid(); err = itr.Next() {
+		if itr.Node.Sequence() < cutoff {
+			seg.Salvaged++
+		}
+	}
+	return seg, cutoff, err
+}
+
+// isSplitBlock returns true if the segment named first-last holds part of the single block first.  Such segments are
+// named <block>-<file seq>, with two parts like a <first>-<last> range; a range which collided with an existing name
+// has three.  A file seq lower than the block can't be a range.  Otherwise the segment is taken for a split block when
+// every record read from it, onlyFirst, is of block first; a range whose damage comes before its second block can't
+// be told apart from one.
+func isSplitBlock(file string, first, last int64, onlyFirst bool) bool {
+	parts := strings.Split(strings.TrimSuffix(filepath.Base(file), segmentSuffix), "-")
+	if len(parts) != 2 {
+		return false
+	}
+	return last < first || onlyFirst
+}
+
+// rewriteSegment moves seg.File into quarantineDir and compacts the records below cutoff back into the segment's
+// directory.  If that fails, what was written is removed and seg.File moved back.
+func rewriteSegment[T Sequenced](seg *RepairedSegment, quarantineDir string, cutoff int64, newNode func() T) error {
+	if err := os.MkdirAll(quarantineDir, 0755); err != nil {
+		return err
+	}
+	seg.Quarantined = filepath.Join(quarantineDir, filepath.Base(seg.File))
+	if api.IsFileExistent(seg.Quarantined) {
+		return fmt.Errorf("file %s already exists", seg.Quarantined)
+	}
+	if err := os.Rename(seg.File, seg.Quarantined); err != nil {
+		return err
+	}
+
+	streamCtx := &StreamingContext{
+		OutDir:       filepath.Dir(seg.File),
+		In:           make(chan Sequenced),
+		MaxFileSize:  math.MaxInt,
+		OrderedInput: seg.Lost != nil,
+	}
+	if !streamCtx.OrderedInput {
+		// keep the <worker>-<file seq> name of unordered segments
+		parts := strings.Split(strings.TrimSuffix(filepath.Base(seg.File), segmentSuffix), "-")
+		if len(parts) == 2 {
+			streamCtx.WorkerId, _ = strconv.Atoi(parts[0])
+			streamCtx.FileSeq, _ = strconv.Atoi(parts[1])
+		}
+	}
+
+	type result struct {
+		stats *Stats
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		stats, err := streamCtx.Compact()
+		done <- result{stats, err}
+	}()
+	var (
+		res         result
+		compactDone bool
+	)
+	itr, err := NewSegmentIterator(seg.Quarantined, newNode, WithRecovery(true))
+	for ; err == nil && itr.Valid(); err = itr.Next() {
+		if itr.Node.Sequence() >= cutoff {
+			continue
+		}
+		select {
+		case streamCtx.In <- itr.Node:
+		case res = <-done:
+			// Compact only returns early on error
+			compactDone = true
+		}
+		if compactDone {
+			break
+		}
+	}
+	close(streamCtx.In)
+	if !compactDone {
+		res = <-done
+	}
+	if err == nil {
+		err = res.err
+	}
+	if err != nil {
+		// put the segment back as it was
+		if res.stats != nil {
+			for _, f := range res.stats.FilesWritten {
+				os.Remove(f)
+			}
+		}
+		if rerr := os.Rename(seg.Quarantined, seg.File); rerr != nil {
+			return fmt.Errorf("%w; restoring %s: %v", err, seg.File, rerr)
+		}
+		seg.Quarantined = ""
+		return err
+	}
+	seg.Rewritten = res.stats.FilesWritten
+	return nil
+}
@@ -0,0 +1,173 @@
+package compact
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+)
+
+// VerifyProblem is a single defect found by Verify.
+type VerifyProblem struct {
+	File string
+	// Record is the index of the offending record within File, or -1 if the problem concerns the file as a whole.
+	Record int
+	Reason string
+}
+
+func (p VerifyProblem) String() string {
+	if p.Record < 0 {
+		return fmt.Sprintf("%s: %s", filepath.Base(p.File), p.Reason)
+	}
+	return fmt.Sprintf("%s record %d: %s", filepath.Base(p.File), p.Record, p.Reason)
+}
+
+// SegmentSummary describes one segment as read by Verify.
+type SegmentSummary struct {
+	File       string
+	Records    int
+	FirstBlock int64
+	LastBlock  int64
+}
+
+type VerifyReport struct {
+	Dir      string
+	Segments []SegmentSummary
+	Records  int
+	Problems []VerifyProblem
+}
+
+// OK returns true if no problems were found.
+func (r *VerifyReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *VerifyReport) problem(file string, record int, format string, args ...any) {
+	r.Problems = append(r.Problems, VerifyProblem{File: file, Record: record, Reason: fmt.Sprintf(format, args...)})
+}
+
+func (r *VerifyReport) Report() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("verify %s:\n", r.Dir))
+	sb.WriteString(fmt.Sprintf("segment count: %d\n", len(r.Segments)))
+	sb.WriteString(fmt.Sprintf("record count: %s\n", humanize.Comma(int64(r.Records))))
+	if len(r.Segments) > 0 {
+		sb.WriteString(fmt.Sprintf("blocks: %d-%d\n", r.Segments[0].FirstBlock, r.Segments[len(r.Segments)-1].LastBlock))
+	}
+	if r.OK() {
+		sb.WriteString("OK\n")
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("problems: %d\n", len(r.Problems)))
+	for _, p := range r.Problems {
+		sb.WriteString(fmt.Sprintf("  %s\n", p))
+	}
+	return sb.String()
+}
+
+// Verify reads every segment in dir and checks that:
+//   - each gzip stream is complete and valid
+//   - each record unmarshals into the type returned by newNode without unknown fields
+//   - sequences never decrease, within a segment or from one segment to the next
+//   - the block range in each segment's name matches the records it holds
+//   - no block height is skipped between one segment and the next
+//
+// Problems are collected in the returned report; an error is only returned if dir cannot be read.  Segments written
+// from unordered input are not named by block range and are only checked for readability.
+func Verify[T Sequenced](dir string, newNode func() T) (*VerifyReport, error) {
+	files, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+	report := &VerifyReport{Dir: dir}
+	var prev *SegmentSummary
+	for _, file := range files {
+		first, last, named := parseSegmentName(file)
+		summary := verifySegment(report, file, newNode, named)
+		if summary.Records == 0 {
+			continue
+		}
+		report.Segments = append(report.Segments, summary)
+		if !named {
+			continue
+		}
+		if !(summary.FirstBlock == first && summary.LastBlock == last) &&
+			// a single block split over several files is named <block>-<file seq>
+			!(summary.FirstBlock == first && summary.LastBlock == first) {
+			report.problem(file, -1, "name does not match contents, blocks %d-%d", summary.FirstBlock, summary.LastBlock)
+		}
+		if prev != nil {
+			switch {
+			case summary.FirstBlock < prev.LastBlock:
+				report.problem(file, 0, "sequence %d is lower than %d at the end of %s",
+					summary.FirstBlock, prev.LastBlock, filepath.Base(prev.File))
+			case summary.FirstBlock > prev.LastBlock+1:
+				report.problem(file, -1, "missing blocks %d-%d", prev.LastBlock+1, summary.FirstBlock-1)
+			}
+		}
+		prev = &summary
+	}
+	return report, nil
+}
+
+func verifySegment[T Sequenced](report *VerifyReport, file string, newNode func() T, ordered bool) SegmentSummary {
+	summary := SegmentSummary{File: file}
+	itr, err := NewSegmentIterator(file, newNode, WithReuseNode(), WithRecovery(false))
+	for ; err == nil && itr.Valid(); err = itr.Next() {
+		seq := itr.Node.Sequence()
+		if summary.Records == 0 {
+			summary.FirstBlock = seq
+		} else if ordered && seq < summary.LastBlock {
+			report.problem(file, summary.Records, "sequence %d is lower than previous %d", seq, summary.LastBlock)
+		}
+		if seq > summary.LastBlock {
+			summary.LastBlock = seq
+		}
+		if seq < summary.FirstBlock {
+			summary.FirstBlock = seq
+		}
+		if unknown := itr.Node.ProtoReflect().GetUnknown(); len(unknown) > 0 {
+			report.problem(file, summary.Records, "%d bytes of unknown fields for %s",
+				len(unknown), itr.Node.ProtoReflect().Descriptor().FullName())
+		}
+		summary.Records++
+	}
+	report.Records += summary.Records
+	var terr *TruncationError
+	switch {
+	case errors.As(err, &terr):
+		report.problem(file, terr.Record, "unreadable at offset %d: %v", terr.Offset, terr.Err)
+	case err != nil:
+		report.problem(file, -1, "%v", err)
+	case summary.Records == 0:
+		report.problem(file, -1, "empty segment")
+	}
+	return summary
+}
+
+// parseSegmentName returns the block range encoded in a segment name by StreamingContext.nextFilename.  named is
+// false for segments written from unordered input, which are named by worker and file sequence instead.
+func parseSegmentName(path string) (first, last int64, named bool) {
+	parts := strings.Split(strings.TrimSuffix(filepath.Base(path), segmentSuffix), "-")
+	if len(parts) > 3 {
+		return 0, 0, false
+	}
+	blocks := make([]int64, len(parts))
+	for i, part := range parts {
+		if len(part) < 8 {
+			return 0, 0, false
+		}
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		blocks[i] = n
+	}
+	if len(blocks) == 1 {
+		return blocks[0], blocks[0], true
+	}
+	return blocks[0], blocks[1], true
+}
@@ -0,0 +1,136 @@
+package decode
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/kocubinski/costor-api/keys"
+)
+
+// AccountsLayout is the layout of the auth store's account keys: 0x01 followed by the address.
+func AccountsLayout(hrp string) *keys.Layout {
+	return keys.NewLayout("Accounts", []byte{0x01}, keys.Field("address", keys.RawAddress(hrp)))
+}
+
+// AccountNumbersLayout is the layout of the auth store's account number keys: "accountNumber" followed by the
+// number, big endian.
+var AccountNumbersLayout = keys.NewLayout("AccountNumbers", []byte("accountNumber"), keys.Field("number", keys.Uint64))
+
+// GlobalAccountNumberLayout is the key of the next account number.
+var GlobalAccountNumberLayout = keys.NewLayout("GlobalAccountNumber", []byte("globalAccountNumber"))
+
+// Account is an account, keyed by address.  Module accounts also have a Name.
+type Account struct {
+	Address       string `json:"address"`
+	TypeURL       string `json:"type_url,omitempty"`
+	AccountNumber uint64 `json:"account_number"`
+	Sequence      uint64 `json:"sequence"`
+	Name          string `json:"name,omitempty"`
+}
+
+// AccountNumber maps an account number to its address.
+type AccountNumber struct {
+	Number  uint64 `json:"number"`
+	Address string `json:"address,omitempty"`
+}
+
+// GlobalAccountNumber is the next account number to be assigned.
+type GlobalAccountNumber struct {
+	Next uint64 `json:"next"`
+}
+
+// baseAccountDepth is the number of base_account (1) fields to descend through, from the account types the SDK
+// registers, to reach the BaseAccount.
+var baseAccountDepth = map[string]int{
+	"/cosmos.auth.v1beta1.BaseAccount":                 0,
+	"/cosmos.auth.v1beta1.ModuleAccount":               1,
+	"/cosmos.vesting.v1beta1.BaseVestingAccount":       1,
+	"/cosmos.vesting.v1beta1.ContinuousVestingAccount": 2,
+	"/cosmos.vesting.v1beta1.DelayedVestingAccount":    2,
+	"/cosmos.vesting.v1beta1.PeriodicVestingAccount":   2,
+	"/cosmos.vesting.v1beta1.PermanentLockedAccount":   2,
+}
+
+// decodeAccount decodes an account stored as an Any (type_url = 1, value = 2), keyed by address.  The account number
+// (3) and sequence (4) are read from its BaseAccount, and the name (2) of a ModuleAccount.
+func decodeAccount(parts []keys.Value, value []byte) (any, error) {
+	a := &Account{Address: parts[0].Text}
+	if value == nil {
+		return a, nil
+	}
+	fs, err := fields(value)
+	if err != nil {
+		return nil, fmt.Errorf("account value: %w", err)
+	}
+	var msg []byte
+	for _, f := range fs {
+		switch f.num {
+		case 1:
+			a.TypeURL = string(f.bytes)
+		case 2:
+			msg = f.bytes
+		}
+	}
+	depth, ok := baseAccountDepth[a.TypeURL]
+	if !ok {
+		return nil, fmt.Errorf("account value: %w %q", ErrUnknownType, a.TypeURL)
+	}
+	for ; depth >= 0; depth-- {
+		fs, err := fields(msg)
+		if err != nil {
+			return nil, fmt.Errorf("account value %s: %w", a.TypeURL, err)
+		}
+		msg = nil
+		for _, f := range fs {
+			switch {
+			case depth == 0 && f.num == 3:
+				a.AccountNumber = f.varint
+			case depth == 0 && f.num == 4:
+				a.Sequence = f.varint
+			case depth > 0 && f.num == 1:
+				msg = f.bytes
+			case a.TypeURL == "/cosmos.auth.v1beta1.ModuleAccount" && depth == 1 && f.num == 2:
+				a.Name = string(f.bytes)
+			}
+		}
+		if depth > 0 && msg == nil {
+			return nil, fmt.Errorf("account value %s: missing base account", a.TypeURL)
+		}
+	}
+	return a, nil
+}
+
+// accountNumberDecoder returns a decoder of the address of an account number, rendered with hrp.
+func accountNumberDecoder(hrp string) func(parts []keys.Value, value []byte) (any, error) {
+	return func(parts []keys.Value, value []byte) (any, error) {
+		n := &AccountNumber{Number: parts[0].Value.(uint64)}
+		if value != nil {
+			n.Address = keys.FormatAddress(hrp, value)
+		}
+		return n, nil
+	}
+}
+
+// decodeGlobalAccountNumber decodes the next account number as SDK 0.50 and later store it, a collections Sequence of 8
+// bytes big endian, or as earlier versions do, a gogoproto UInt64Value: value = 1.  A UInt64Value is only 8 bytes long
+// above 2^42, and a Sequence starts with a zero byte, which is not a valid field tag, until then.
+func decodeGlobalAccountNumber(_ []keys.Value, value []byte) (any, error) {
+	g := &GlobalAccountNumber{}
+	if value == nil {
+		return g, nil
+	}
+	if len(value) == 8 {
+		g.Next = binary.BigEndian.Uint64(value)
+		return g, nil
+	}
+	fs, err := fields(value)
+	if err != nil {
+		return nil, fmt.Errorf("global account number value: %w", err)
+	}
+	for _, f := range fs {
+		if f.num == 1 {
+			g.Next = f.varint
+		}
+	}
+	return g, nil
+}
@@ -0,0 +1,97 @@
+package decode
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kocubinski/costor-api/keys"
+)
+
+// SupplyLayout is the layout of the bank store's supply keys: 0x00 followed by the denom.
+var SupplyLayout = keys.NewLayout("Supply", []byte{0x00}, keys.Field("denom", keys.String))
+
+// BalancesLayout is the layout of the bank store's balance keys: 0x02, the length prefixed address and the denom.
+func BalancesLayout(hrp string) *keys.Layout {
+	return keys.NewLayout("Balances", []byte{0x02},
+		keys.Field("address", keys.Address(hrp)),
+		keys.Field("denom", keys.String),
+	)
+}
+
+// Balance is a bank balance, keyed by address and denom.
+type Balance struct {
+	Address string `json:"address"`
+	Denom   string `json:"denom"`
+	// Amount is empty for deletes.
+	Amount string `json:"amount,omitempty"`
+}
+
+// Supply is the total supply of a denom.
+type Supply struct {
+	Denom  string `json:"denom"`
+	Amount string `json:"amount,omitempty"`
+}
+
+// decodeBalance decodes a balance.  Since SDK v0.46 the value is the amount as an integer in text; before it was a
+// Coin.
+func decodeBalance(parts []keys.Value, value []byte) (any, error) {
+	b := &Balance{Address: parts[0].Text, Denom: parts[1].Text}
+	if b.Denom == "" {
+		return nil, fmt.Errorf("balance key: %w: missing denom", ErrKey)
+	}
+	if value == nil {
+		return b, nil
+	}
+	var err error
+	if b.Amount, err = parseInt(value); err == nil {
+		return b, nil
+	}
+	coinDenom, amount, coinErr := decodeCoin(value)
+	if coinErr != nil {
+		return nil, fmt.Errorf("balance value is neither an integer nor a Coin: %w", err)
+	}
+	if coinDenom != b.Denom {
+		return nil, fmt.Errorf("balance of %s keyed by denom %s", coinDenom, b.Denom)
+	}
+	b.Amount = amount
+	return b, nil
+}
+
+// decodeCoin decodes a cosmos.base.v1beta1.Coin: denom = 1, amount = 2.
+func decodeCoin(bz []byte) (denom, amount string, err error) {
+	fs, err := fields(bz)
+	if err != nil {
+		return "", "", err
+	}
+	for _, f := range fs {
+		switch f.num {
+		case 1:
+			denom = string(f.bytes)
+		case 2:
+			if amount, err = parseInt(f.bytes); err != nil {
+				return "", "", err
+			}
+		}
+	}
+	if denom == "" {
+		return "", "", errors.New("coin without denom")
+	}
+	return denom, amount, nil
+}
+
+// decodeSupply decodes the supply of a denom, an integer in text.
+func decodeSupply(parts []keys.Value, value []byte) (any, error) {
+	s := &Supply{Denom: parts[0].Text}
+	if s.Denom == "" {
+		return nil, fmt.Errorf("supply key: %w: missing denom", ErrKey)
+	}
+	if value == nil {
+		return s, nil
+	}
+	amount, err := parseInt(value)
+	if err != nil {
+		return nil, fmt.Errorf("supply value: %w", err)
+	}
+	s.Amount = amount
+	return s, nil
+}
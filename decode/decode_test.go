@@ -0,0 +1,240 @@
+package decode_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/compact"
+	"github.com/kocubinski/costor-api/decode"
+	"github.com/kocubinski/costor-api/keys"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var addr = []byte{0xde, 0xad, 0xbe, 0xef}
+
+func lengthPrefixed(bz []byte) []byte {
+	return append([]byte{byte(len(bz))}, bz...)
+}
+
+func msg(fields ...func([]byte) []byte) []byte {
+	var bz []byte
+	for _, f := range fields {
+		bz = f(bz)
+	}
+	return bz
+}
+
+func bytesField(num protowire.Number, v []byte) func([]byte) []byte {
+	return func(bz []byte) []byte {
+		bz = protowire.AppendTag(bz, num, protowire.BytesType)
+		return protowire.AppendBytes(bz, v)
+	}
+}
+
+func varintField(num protowire.Number, v uint64) func([]byte) []byte {
+	return func(bz []byte) []byte {
+		bz = protowire.AppendTag(bz, num, protowire.VarintType)
+		return protowire.AppendVarint(bz, v)
+	}
+}
+
+func anyValue(typeURL string, value []byte) []byte {
+	return msg(bytesField(1, []byte(typeURL)), bytesField(2, value))
+}
+
+func TestSDKDecoders(t *testing.T) {
+	baseAccount := msg(bytesField(1, []byte("cosmos1...")), varintField(3, 42), varintField(4, 7))
+	cases := []struct {
+		name     string
+		node     *api.Node
+		expected any
+	}{
+		{
+			"balance",
+			&api.Node{StoreKey: "bank", Key: append(append([]byte{0x02}, lengthPrefixed(addr)...), "uatom"...),
+				Value: []byte("1000")},
+			&decode.Balance{Address: "DEADBEEF", Denom: "uatom", Amount: "1000"},
+		},
+		{
+			"legacy coin balance",
+			&api.Node{StoreKey: "bank", Key: append(append([]byte{0x02}, lengthPrefixed(addr)...), "uatom"...),
+				Value: msg(bytesField(1, []byte("uatom")), bytesField(2, []byte("5")))},
+			&decode.Balance{Address: "DEADBEEF", Denom: "uatom", Amount: "5"},
+		},
+		{
+			"deleted balance",
+			&api.Node{StoreKey: "bank", Key: append(append([]byte{0x02}, lengthPrefixed(addr)...), "uatom"...),
+				Delete: true},
+			&decode.Balance{Address: "DEADBEEF", Denom: "uatom"},
+		},
+		{
+			"supply",
+			&api.Node{StoreKey: "bank", Key: append([]byte{0x00}, "uatom"...), Value: []byte("123456789")},
+			&decode.Supply{Denom: "uatom", Amount: "123456789"},
+		},
+		{
+			"validator",
+			&api.Node{StoreKey: "staking", Key: append([]byte{0x21}, lengthPrefixed(addr)...), Value: msg(
+				bytesField(1, []byte("cosmosvaloper1...")),
+				varintField(3, 1),
+				varintField(4, 3),
+				bytesField(5, []byte("100")),
+				bytesField(6, []byte("100.000000000000000000")),
+				bytesField(7, msg(bytesField(1, []byte("costor")))),
+			)},
+			&decode.Validator{Operator: "DEADBEEF", OperatorAddress: "cosmosvaloper1...", Moniker: "costor", Jailed: true,
+				Status: "BOND_STATUS_BONDED", Tokens: "100", DelegatorShares: "100.000000000000000000"},
+		},
+		{
+			"base account",
+			&api.Node{StoreKey: "acc", Key: append([]byte{0x01}, addr...),
+				Value: anyValue("/cosmos.auth.v1beta1.BaseAccount", baseAccount)},
+			&decode.Account{Address: "DEADBEEF", TypeURL: "/cosmos.auth.v1beta1.BaseAccount", AccountNumber: 42, Sequence: 7},
+		},
+		{
+			"module account",
+			&api.Node{StoreKey: "acc", Key: append([]byte{0x01}, addr...),
+				Value: anyValue("/cosmos.auth.v1beta1.ModuleAccount",
+					msg(bytesField(1, baseAccount), bytesField(2, []byte("distribution"))))},
+			&decode.Account{Address: "DEADBEEF", TypeURL: "/cosmos.auth.v1beta1.ModuleAccount", AccountNumber: 42,
+				Sequence: 7, Name: "distribution"},
+		},
+		{
+			"account number",
+			&api.Node{StoreKey: "acc", Key: binary.BigEndian.AppendUint64([]byte("accountNumber"), 42), Value: addr},
+			&decode.AccountNumber{Number: 42, Address: "DEADBEEF"},
+		},
+		{
+			"global account number",
+			&api.Node{StoreKey: "acc", Key: []byte("globalAccountNumber"), Value: msg(varintField(1, 43))},
+			&decode.GlobalAccountNumber{Next: 43},
+		},
+		{
+			"global account number sequence",
+			&api.Node{StoreKey: "acc", Key: []byte("globalAccountNumber"),
+				Value: binary.BigEndian.AppendUint64(nil, 43)},
+			&decode.GlobalAccountNumber{Next: 43},
+		},
+	}
+	r := decode.NewSDKRegistry("")
+	for _, tc := range cases {
+		decoded, decodeErr := r.Decode(tc.node)
+		require.Nil(t, decodeErr, tc.name)
+		require.NotNil(t, decoded, tc.name)
+		require.Equal(t, tc.expected, decoded.Value, tc.name)
+	}
+
+	// no decoder
+	decoded, decodeErr := r.Decode(&api.Node{StoreKey: "bank", Key: []byte{0x03}})
+	require.Nil(t, decoded)
+	require.Nil(t, decodeErr)
+
+	failures := []struct {
+		node        *api.Node
+		humanPrefix string
+		code        api.DecodeErrorCode
+	}{
+		{&api.Node{StoreKey: "bank", Key: []byte{0x02, 0x10, 0x01}, Value: []byte("1")}, "Balances",
+			api.DecodeErrorCode_DECODE_ERROR_KEY},
+		{&api.Node{StoreKey: "bank", Key: append(append([]byte{0x02}, lengthPrefixed(addr)...), "uatom"...),
+			Value: []byte("1.5")}, "Balances", api.DecodeErrorCode_DECODE_ERROR_VALUE},
+		{&api.Node{StoreKey: "bank", Key: []byte{0x00}, Value: []byte("1")}, "Supply",
+			api.DecodeErrorCode_DECODE_ERROR_KEY},
+		{&api.Node{StoreKey: "bank", Key: []byte{0x00, 'a'}, Value: []byte("x")}, "Supply",
+			api.DecodeErrorCode_DECODE_ERROR_VALUE},
+		{&api.Node{StoreKey: "staking", Key: append([]byte{0x21}, lengthPrefixed(addr)...), Value: []byte{0xff}},
+			"Validators", api.DecodeErrorCode_DECODE_ERROR_VALUE},
+		{&api.Node{StoreKey: "acc", Key: append([]byte{0x01}, addr...), Value: anyValue("/other.Account", nil)},
+			"Accounts", api.DecodeErrorCode_DECODE_ERROR_UNKNOWN_TYPE},
+		{&api.Node{StoreKey: "acc", Key: []byte("accountNumber\x01")}, "AccountNumbers",
+			api.DecodeErrorCode_DECODE_ERROR_KEY},
+	}
+	for _, tc := range failures {
+		decoded, decodeErr := r.Decode(tc.node)
+		require.Nil(t, decoded)
+		require.NotNil(t, decodeErr, "%v", tc.node)
+		require.Equal(t, tc.humanPrefix, decodeErr.HumanPrefix)
+		require.Equal(t, tc.code, decodeErr.Code, decodeErr.Reason)
+		require.Equal(t, tc.node.StoreKey, decodeErr.StoreKey)
+		require.NotEmpty(t, decodeErr.Reason)
+		require.Same(t, tc.node, decodeErr.Node)
+	}
+}
+
+func TestSDKDecoders_Bech32(t *testing.T) {
+	r := decode.NewSDKRegistry("cosmos")
+	accAddr, err := keys.Bech32Encode("cosmos", addr)
+	require.NoError(t, err)
+	valAddr, err := keys.Bech32Encode("cosmosvaloper", addr)
+	require.NoError(t, err)
+	decoded, decodeErr := r.Decode(&api.Node{StoreKey: "bank",
+		Key: append(append([]byte{0x02}, lengthPrefixed(addr)...), "uatom"...), Value: []byte("1")})
+	require.Nil(t, decodeErr)
+	require.Equal(t, &decode.Balance{Address: accAddr, Denom: "uatom", Amount: "1"}, decoded.Value)
+
+	decoded, decodeErr = r.Decode(&api.Node{StoreKey: "staking", Key: append([]byte{0x21}, lengthPrefixed(addr)...),
+		Value: msg(bytesField(1, []byte("cosmosvaloper1...")))})
+	require.Nil(t, decodeErr)
+	require.Equal(t, valAddr, decoded.Value.(*decode.Validator).Operator)
+}
+
+func TestRegistry(t *testing.T) {
+	short := &decode.Decoder{StoreKey: "s", Prefix: []byte{0x01}, HumanPrefix: "Short",
+		Decode: func(key, value []byte) (any, error) { return "short", nil }}
+	long := &decode.Decoder{StoreKey: "s", Prefix: []byte{0x01, 0x02}, HumanPrefix: "Long",
+		Decode: func(key, value []byte) (any, error) { return string(key), nil }}
+	r, err := decode.NewRegistry(short, long)
+	require.NoError(t, err)
+	require.Error(t, r.Register(&decode.Decoder{StoreKey: "s", Prefix: []byte{0x01}}))
+
+	require.Equal(t, long, r.Lookup("s", []byte{0x01, 0x02, 0x03}))
+	require.Equal(t, short, r.Lookup("s", []byte{0x01, 0x03}))
+	require.Nil(t, r.Lookup("s", []byte{0x02}))
+	require.Nil(t, r.Lookup("other", []byte{0x01}))
+	decoded, _ := r.Decode(&api.Node{StoreKey: "s", Key: []byte{0x01, 0x02, 'k'}})
+	require.Equal(t, "k", decoded.Value)
+}
+
+func TestRoute(t *testing.T) {
+	cs := &api.Changeset{Nodes: []*api.Node{
+		{StoreKey: "bank", Key: []byte{0x00, 'a'}, Value: []byte("1"), Block: 1},
+		{StoreKey: "bank", Key: []byte{0x00, 'b'}, Value: []byte("bad"), Block: 1},
+		{StoreKey: "gov", Key: []byte{0x00}, Value: []byte("?"), Block: 2},
+		{StoreKey: "bank", Key: []byte{0x00, 'c'}, Value: []byte("bad"), Block: 2},
+	}}
+	errDir := t.TempDir()
+	errCtx := &compact.StreamingContext{
+		OutDir:       errDir,
+		MaxFileSize:  1024,
+		OrderedInput: true,
+		In:           make(chan compact.Sequenced),
+	}
+	done := make(chan error)
+	go func() {
+		_, err := errCtx.Compact()
+		done <- err
+	}()
+	var decoded []*decode.Decoded
+	stats, err := decode.NewSDKRegistry("").Route(cs.Iterator(), func(d *decode.Decoded) error {
+		decoded = append(decoded, d)
+		return nil
+	}, errCtx.In)
+	require.NoError(t, err)
+	close(errCtx.In)
+	require.NoError(t, <-done)
+	require.Equal(t, decode.RouteStats{Decoded: 1, Failed: 2, Unknown: 1}, stats)
+	require.Len(t, decoded, 1)
+
+	itr, err := compact.NewSequencedIterator(errDir, func() *api.DecodeError { return &api.DecodeError{} })
+	require.NoError(t, err)
+	var denoms []string
+	for ; itr.Valid(); err = itr.Next() {
+		require.NoError(t, err)
+		require.Equal(t, "Supply", itr.Node.HumanPrefix)
+		denoms = append(denoms, string(itr.Node.Node.Key[1:]))
+	}
+	require.NoError(t, err)
+	require.Equal(t, []string{"b", "c"}, denoms)
+}
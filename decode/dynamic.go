@@ -0,0 +1,244 @@
+package decode
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// DescriptorsDir is the directory under core.Context.HomeDir which holds descriptor sets and their TypesFile.
+const DescriptorsDir = "descriptors"
+
+// TypesFile is the file of a descriptors directory mapping store keys and prefixes to message types.
+const TypesFile = "types.json"
+
+// descriptorSetExts are the extensions of descriptor set files, as written by protoc --descriptor_set_out or
+// buf build -o.
+var descriptorSetExts = []string{".pb", ".binpb", ".protoset"}
+
+// LoadDescriptorSets reads every FileDescriptorSet in dir into one set of files.  A file may be included in several
+// sets, as google/protobuf/any.proto and gogoproto usually are, as long as every copy is the same.
+func LoadDescriptorSets(dir string) (*protoregistry.Files, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*descriptorpb.FileDescriptorProto)
+	for _, e := range entries {
+		if e.IsDir() || !hasDescriptorSetExt(e.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		bz, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		set := &descriptorpb.FileDescriptorSet{}
+		if err := proto.Unmarshal(bz, set); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, f := range set.File {
+			if other, ok := byName[f.GetName()]; ok {
+				if !proto.Equal(other, f) {
+					return nil, fmt.Errorf("%s: %s differs from the copy in another descriptor set", path, f.GetName())
+				}
+				continue
+			}
+			byName[f.GetName()] = f
+		}
+	}
+	merged := &descriptorpb.FileDescriptorSet{File: make([]*descriptorpb.FileDescriptorProto, 0, len(byName))}
+	for _, f := range byName {
+		merged.File = append(merged.File, f)
+	}
+	sort.Slice(merged.File, func(i, j int) bool { return merged.File[i].GetName() < merged.File[j].GetName() })
+	files, err := protodesc.NewFiles(merged)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dir, err)
+	}
+	return files, nil
+}
+
+func hasDescriptorSetExt(name string) bool {
+	for _, ext := range descriptorSetExts {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// DynamicType maps the values under a key prefix of a store to a protobuf message type.
+type DynamicType struct {
+	StoreKey string
+	Prefix   []byte
+	// HumanPrefix names the prefix; it defaults to the message name.
+	HumanPrefix string
+	// Message is the full name of the message type, such as cosmos.bank.v1beta1.Metadata.
+	Message protoreflect.FullName
+}
+
+// dynamicTypeJSON is the form of a DynamicType in a TypesFile, with the prefix in hex.
+type dynamicTypeJSON struct {
+	StoreKey    string `json:"store_key"`
+	Prefix      string `json:"prefix"`
+	HumanPrefix string `json:"human_prefix,omitempty"`
+	Message     string `json:"message"`
+}
+
+// LoadDynamicTypes reads a TypesFile, a JSON list of objects such as
+//
+//	{"store_key": "bank", "prefix": "01", "human_prefix": "DenomMetadata", "message": "cosmos.bank.v1beta1.Metadata"}
+func LoadDynamicTypes(path string) ([]DynamicType, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var in []dynamicTypeJSON
+	if err := json.Unmarshal(bz, &in); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	types := make([]DynamicType, len(in))
+	for i, t := range in {
+		prefix, err := hex.DecodeString(t.Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("%s: prefix of %s: %w", path, t.Message, err)
+		}
+		types[i] = DynamicType{
+			StoreKey:    t.StoreKey,
+			Prefix:      prefix,
+			HumanPrefix: t.HumanPrefix,
+			Message:     protoreflect.FullName(t.Message),
+		}
+	}
+	return types, nil
+}
+
+// DynamicValue is a value decoded through a descriptor set.
+type DynamicValue struct {
+	Type protoreflect.FullName `json:"type"`
+	// JSON is the value in canonical protobuf JSON; it is empty for deletes.
+	JSON json.RawMessage `json:"json,omitempty"`
+}
+
+// DynamicDecoders returns a decoder for each of types, which decodes values as messages described by files.  Any
+// fields are resolved against files too.
+func DynamicDecoders(files *protoregistry.Files, types []DynamicType) ([]*Decoder, error) {
+	resolver := dynamicpb.NewTypes(files)
+	decoders := make([]*Decoder, len(types))
+	for i, t := range types {
+		desc, err := files.FindDescriptorByName(t.Message)
+		if err != nil {
+			return nil, fmt.Errorf("store %s prefix %X: %s: %w", t.StoreKey, t.Prefix, t.Message, err)
+		}
+		md, ok := desc.(protoreflect.MessageDescriptor)
+		if !ok {
+			return nil, fmt.Errorf("store %s prefix %X: %s is not a message", t.StoreKey, t.Prefix, t.Message)
+		}
+		humanPrefix := t.HumanPrefix
+		if humanPrefix == "" {
+			humanPrefix = string(md.Name())
+		}
+		decoders[i] = &Decoder{
+			StoreKey:    t.StoreKey,
+			Prefix:      t.Prefix,
+			HumanPrefix: humanPrefix,
+			Decode:      dynamicDecoder(md, resolver),
+		}
+	}
+	return decoders, nil
+}
+
+func dynamicDecoder(md protoreflect.MessageDescriptor, resolver *dynamicpb.Types) func(key, value []byte) (any, error) {
+	unmarshal := proto.UnmarshalOptions{Resolver: resolver}
+	marshal := protojson.MarshalOptions{Resolver: resolver}
+	return func(_, value []byte) (any, error) {
+		v := &DynamicValue{Type: md.FullName()}
+		if value == nil {
+			return v, nil
+		}
+		msg := dynamicpb.NewMessage(md)
+		if err := unmarshal.Unmarshal(value, msg); err != nil {
+			return nil, fmt.Errorf("%s: %w", md.FullName(), err)
+		}
+		bz, err := marshal.Marshal(msg)
+		if err != nil {
+			if url, ok := unresolvedAny(msg.ProtoReflect(), resolver, unmarshal); ok {
+				return nil, fmt.Errorf("%s: %w %q", md.FullName(), ErrUnknownType, url)
+			}
+			return nil, fmt.Errorf("%s: %w", md.FullName(), err)
+		}
+		// protojson deliberately varies its whitespace; compacting it makes the output stable
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, bz); err != nil {
+			return nil, fmt.Errorf("%s: %w", md.FullName(), err)
+		}
+		v.JSON = buf.Bytes()
+		return v, nil
+	}
+}
+
+// unresolvedAny returns the type URL of the first Any in m, or in the messages packed in its Anys, which resolver does
+// not know.  protojson can't print such an Any and fails with an error which doesn't tell it apart from a bad value.
+func unresolvedAny(
+	m protoreflect.Message, resolver *dynamicpb.Types, unmarshal proto.UnmarshalOptions,
+) (url string, ok bool) {
+	if m.Descriptor().FullName() == anyFullName {
+		url = m.Get(m.Descriptor().Fields().ByNumber(1)).String()
+		mt, err := resolver.FindMessageByURL(url)
+		if err != nil {
+			return url, true
+		}
+		packed := mt.New()
+		if err := unmarshal.Unmarshal(m.Get(m.Descriptor().Fields().ByNumber(2)).Bytes(), packed.Interface()); err != nil {
+			return "", false
+		}
+		return unresolvedAny(packed, resolver, unmarshal)
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			for i, l := 0, v.List(); i < l.Len() && !ok; i++ {
+				url, ok = unresolvedAny(l.Get(i).Message(), resolver, unmarshal)
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
+				url, ok = unresolvedAny(v.Message(), resolver, unmarshal)
+				return !ok
+			})
+		case fd.Message() != nil && !fd.IsList() && !fd.IsMap():
+			url, ok = unresolvedAny(v.Message(), resolver, unmarshal)
+		}
+		return !ok
+	})
+	return url, ok
+}
+
+const anyFullName protoreflect.FullName = "google.protobuf.Any"
+
+// LoadDynamic returns the decoders of the descriptor sets and TypesFile in dir, such as DescriptorsDir under
+// core.Context.HomeDir.
+func LoadDynamic(dir string) ([]*Decoder, error) {
+	files, err := LoadDescriptorSets(dir)
+	if err != nil {
+		return nil, err
+	}
+	types, err := LoadDynamicTypes(filepath.Join(dir, TypesFile))
+	if err != nil {
+		return nil, err
+	}
+	return DynamicDecoders(files, types)
+}
@@ -0,0 +1,104 @@
+package decode_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/decode"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func writeDescriptorSet(t *testing.T, path string, files ...*descriptorpb.FileDescriptorProto) {
+	bz, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: files})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, bz, 0644))
+}
+
+func TestDynamicDecoders(t *testing.T) {
+	dir := t.TempDir()
+	anyFile := protodesc.ToFileDescriptorProto(anypb.File_google_protobuf_any_proto)
+	holderFile := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("test/holder.proto"),
+		Package:    proto.String("test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/any.proto", "ibc.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Holder"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("held"),
+				JsonName: proto.String("held"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".google.protobuf.Any"),
+			}},
+		}},
+	}
+	ibcFile := protodesc.ToFileDescriptorProto(api.File_ibc_proto)
+	// any.proto is in both sets, as it is in the sets of most chains
+	writeDescriptorSet(t, filepath.Join(dir, "ibc.binpb"), anyFile, ibcFile)
+	writeDescriptorSet(t, filepath.Join(dir, "holder.pb"), holderFile, anyFile)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, decode.TypesFile), []byte(`[
+		{"store_key": "transfer", "prefix": "02", "human_prefix": "DenomTraces", "message": "DenomTrace"},
+		{"store_key": "test", "prefix": "01", "message": "test.Holder"}
+	]`), 0644))
+
+	decoders, err := decode.LoadDynamic(dir)
+	require.NoError(t, err)
+	require.Len(t, decoders, 2)
+	r, err := decode.NewRegistry(decoders...)
+	require.NoError(t, err)
+
+	trace := &api.DenomTrace{Path: "transfer/channel-0", BaseDenom: "uatom"}
+	traceBz, err := proto.Marshal(trace)
+	require.NoError(t, err)
+	decoded, decodeErr := r.Decode(&api.Node{StoreKey: "transfer", Key: append([]byte{0x02}, trace.Hash()...),
+		Value: traceBz})
+	require.Nil(t, decodeErr)
+	require.Equal(t, "DenomTraces", decoded.HumanPrefix)
+	require.Equal(t, &decode.DynamicValue{Type: "DenomTrace",
+		JSON: json.RawMessage(`{"path":"transfer/channel-0","baseDenom":"uatom"}`)}, decoded.Value)
+
+	held, err := anypb.New(trace)
+	require.NoError(t, err)
+	holderBz := msg(bytesField(1, msg(bytesField(1, []byte(held.TypeUrl)), bytesField(2, held.Value))))
+	decoded, decodeErr = r.Decode(&api.Node{StoreKey: "test", Key: []byte{0x01, 'k'}, Value: holderBz})
+	require.Nil(t, decodeErr)
+	require.Equal(t, "Holder", decoded.HumanPrefix)
+	require.JSONEq(t, `{"held":{"@type":"type.googleapis.com/DenomTrace","path":"transfer/channel-0","baseDenom":"uatom"}}`,
+		string(decoded.Value.(*decode.DynamicValue).JSON))
+
+	// Any fields holding types missing from the descriptor sets, directly or packed in another Any
+	unknownBz := msg(bytesField(1, msg(bytesField(1, []byte("/cosmos.bank.v1beta1.Metadata")), bytesField(2, nil))))
+	nestedBz := msg(bytesField(1, msg(bytesField(1, []byte("/test.Holder")), bytesField(2, unknownBz))))
+	for _, value := range [][]byte{unknownBz, nestedBz} {
+		decoded, decodeErr = r.Decode(&api.Node{StoreKey: "test", Key: []byte{0x01, 'k'}, Value: value})
+		require.Nil(t, decoded)
+		require.NotNil(t, decodeErr)
+		require.Equal(t, api.DecodeErrorCode_DECODE_ERROR_UNKNOWN_TYPE, decodeErr.Code)
+		require.Contains(t, decodeErr.Reason, "/cosmos.bank.v1beta1.Metadata")
+	}
+
+	decoded, decodeErr = r.Decode(&api.Node{StoreKey: "test", Key: []byte{0x01, 'k'}, Delete: true})
+	require.Nil(t, decodeErr)
+	require.Equal(t, &decode.DynamicValue{Type: "test.Holder"}, decoded.Value)
+
+	decoded, decodeErr = r.Decode(&api.Node{StoreKey: "transfer", Key: []byte{0x02}, Value: []byte{0xff}})
+	require.Nil(t, decoded)
+	require.NotNil(t, decodeErr)
+	require.Equal(t, "DenomTraces", decodeErr.HumanPrefix)
+	require.Contains(t, decodeErr.Reason, "DenomTrace")
+
+	// unknown message types are rejected up front
+	require.NoError(t, os.WriteFile(filepath.Join(dir, decode.TypesFile),
+		[]byte(`[{"store_key": "bank", "prefix": "01", "message": "cosmos.bank.v1beta1.Metadata"}]`), 0644))
+	_, err = decode.LoadDynamic(dir)
+	require.ErrorContains(t, err, "cosmos.bank.v1beta1.Metadata")
+}
@@ -0,0 +1,185 @@
+package decode
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/compact"
+)
+
+// RedecodeGroup counts the records of one store key and normalized reason.
+type RedecodeGroup struct {
+	StoreKey string `json:"store_key"`
+	// Reason is normalized by NormalizeReason.
+	Reason string `json:"reason"`
+	// Fixed counts the records which failed with Reason and now decode.
+	Fixed int64 `json:"fixed"`
+	// Failed counts the records which now fail with Reason.
+	Failed int64 `json:"failed"`
+}
+
+// RedecodeStats summarizes a Redecode pass.
+type RedecodeStats struct {
+	Dir   string `json:"dir"`
+	Read  int64  `json:"read"`
+	Fixed int64  `json:"fixed"`
+	// Failed counts the records which still fail to decode.
+	Failed int64 `json:"failed"`
+	// Unknown counts the records which no longer have a decoder.  They are copied to the error output as they were.
+	Unknown    int64           `json:"unknown"`
+	Groups     []RedecodeGroup `json:"groups"`
+	NodeFiles  []string        `json:"node_files"`
+	ErrorFiles []string        `json:"error_files"`
+}
+
+func (s *RedecodeStats) Report() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("redecode %s:\n", s.Dir))
+	sb.WriteString(fmt.Sprintf("read: %s\n", humanize.Comma(s.Read)))
+	sb.WriteString(fmt.Sprintf("fixed: %s\n", humanize.Comma(s.Fixed)))
+	sb.WriteString(fmt.Sprintf("failed: %s\n", humanize.Comma(s.Failed)))
+	sb.WriteString(fmt.Sprintf("no decoder: %s\n", humanize.Comma(s.Unknown)))
+	sb.WriteString(fmt.Sprintf("files written: %d nodes, %d errors\n", len(s.NodeFiles), len(s.ErrorFiles)))
+	for _, g := range s.Groups {
+		sb.WriteString(fmt.Sprintf("  %s: %s fixed, %s failed: %s\n", g.StoreKey,
+			humanize.Comma(g.Fixed), humanize.Comma(g.Failed), g.Reason))
+	}
+	return sb.String()
+}
+
+// Redecode runs the decode errors in dir through r again, after its decoders have been fixed.  The node of every error
+// which now decodes is sent to nodes, and the errors which remain to errs, with their new reason.  Errors for which r
+// has no decoder are sent to errs unchanged.  The In of nodes and errs is created if nil and closed by Redecode.  If
+// Redecode fails, the segments it wrote to either are removed.
+//
+// The stats group the records by store key and reason: a fixed record counts under the reason it used to fail with, a
+// failed one under the reason it fails with now.
+func Redecode(dir string, r *Registry, nodes, errs *compact.StreamingContext) (*RedecodeStats, error) {
+	stats := &RedecodeStats{Dir: dir}
+	itr, err := compact.NewSequencedIterator(dir, func() *api.DecodeError { return &api.DecodeError{} })
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		stats *compact.Stats
+		err   error
+	}
+	compactAll := func(c *compact.StreamingContext) chan result {
+		if c.In == nil {
+			c.In = make(chan compact.Sequenced)
+		}
+		done := make(chan result, 1)
+		go func() {
+			stats, err := c.Compact()
+			done <- result{stats, err}
+		}()
+		return done
+	}
+	nodesDone, errsDone := compactAll(nodes), compactAll(errs)
+
+	groups := make(map[[2]string]*RedecodeGroup)
+	group := func(storeKey, reason string) *RedecodeGroup {
+		reason = NormalizeReason(reason)
+		k := [2]string{storeKey, reason}
+		g, ok := groups[k]
+		if !ok {
+			g = &RedecodeGroup{StoreKey: storeKey, Reason: reason}
+			groups[k] = g
+		}
+		return g
+	}
+	var (
+		nodesRes, errsRes           result
+		nodesReturned, errsReturned bool
+	)
+	// send passes rec to in, unless either compaction has returned, which it only does early on error
+	send := func(in chan<- compact.Sequenced, rec compact.Sequenced) bool {
+		select {
+		case in <- rec:
+			return true
+		case nodesRes = <-nodesDone:
+			nodesReturned = true
+		case errsRes = <-errsDone:
+			errsReturned = true
+		}
+		return false
+	}
+	for ; itr.Valid(); err = itr.Next() {
+		if err != nil {
+			break
+		}
+		stats.Read++
+		prev := itr.Node
+		if prev.Node == nil {
+			err = fmt.Errorf("decode error %d of %s has no node", stats.Read, dir)
+			break
+		}
+		var (
+			in  chan<- compact.Sequenced
+			rec compact.Sequenced
+		)
+		decoded, decodeErr := r.Decode(prev.Node)
+		switch {
+		case decodeErr != nil:
+			in, rec = errs.In, decodeErr
+			group(decodeErr.StoreKey, decodeErr.Reason).Failed++
+			stats.Failed++
+		case decoded == nil:
+			in, rec = errs.In, prev
+			stats.Unknown++
+		default:
+			in, rec = nodes.In, prev.Node
+			group(prev.StoreKey, prev.Reason).Fixed++
+			stats.Fixed++
+		}
+		if !send(in, rec) {
+			break
+		}
+	}
+	close(nodes.In)
+	close(errs.In)
+	if !nodesReturned {
+		nodesRes = <-nodesDone
+	}
+	if !errsReturned {
+		errsRes = <-errsDone
+	}
+	for _, resErr := range []error{nodesRes.err, errsRes.err} {
+		if err == nil {
+			err = resErr
+		}
+	}
+	if err != nil {
+		for _, res := range []result{nodesRes, errsRes} {
+			if res.stats == nil {
+				continue
+			}
+			for _, f := range res.stats.FilesWritten {
+				os.Remove(f)
+			}
+		}
+		return nil, err
+	}
+	stats.NodeFiles = nodesRes.stats.FilesWritten
+	stats.ErrorFiles = errsRes.stats.FilesWritten
+
+	for _, g := range groups {
+		stats.Groups = append(stats.Groups, *g)
+	}
+	sort.Slice(stats.Groups, func(i, j int) bool {
+		a, b := stats.Groups[i], stats.Groups[j]
+		if a.StoreKey != b.StoreKey {
+			return a.StoreKey < b.StoreKey
+		}
+		if a.Fixed+a.Failed != b.Fixed+b.Failed {
+			return a.Fixed+a.Failed > b.Fixed+b.Failed
+		}
+		return a.Reason < b.Reason
+	})
+	return stats, nil
+}
@@ -0,0 +1,125 @@
+package decode_test
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/compact"
+	"github.com/kocubinski/costor-api/decode"
+	"github.com/stretchr/testify/require"
+)
+
+func writeDecodeErrors(t *testing.T, dir string, errs ...*api.DecodeError) {
+	c := &compact.StreamingContext{
+		OutDir:       dir,
+		MaxFileSize:  1024,
+		OrderedInput: true,
+		In:           make(chan compact.Sequenced),
+	}
+	done := make(chan error)
+	go func() {
+		_, err := c.Compact()
+		done <- err
+	}()
+	for _, e := range errs {
+		c.In <- e
+	}
+	close(c.In)
+	require.NoError(t, <-done)
+}
+
+func TestRedecode(t *testing.T) {
+	dir := t.TempDir()
+	writeDecodeErrors(t, dir,
+		&api.DecodeError{StoreKey: "bank", HumanPrefix: "Supply", Reason: "old supply decoder", Node: &api.Node{
+			StoreKey: "bank", Key: []byte{0x00, 'a'}, Value: []byte("1"), Block: 1}},
+		&api.DecodeError{StoreKey: "bank", HumanPrefix: "Supply", Reason: "old supply decoder", Node: &api.Node{
+			StoreKey: "bank", Key: []byte{0x00, 'b'}, Value: []byte("x"), Block: 1}},
+		&api.DecodeError{StoreKey: "gov", HumanPrefix: "Proposals", Reason: "gov decoder", Node: &api.Node{
+			StoreKey: "gov", Key: []byte{0x00}, Value: []byte("?"), Block: 2}},
+		&api.DecodeError{StoreKey: "bank", HumanPrefix: "Supply", Reason: "old supply decoder", Node: &api.Node{
+			StoreKey: "bank", Key: []byte{0x00, 'c'}, Value: []byte("2"), Block: 3}},
+	)
+
+	nodesDir, errsDir := t.TempDir(), t.TempDir()
+	stats, err := decode.Redecode(dir, decode.NewSDKRegistry(""),
+		&compact.StreamingContext{OutDir: nodesDir, MaxFileSize: 1024, OrderedInput: true},
+		&compact.StreamingContext{OutDir: errsDir, MaxFileSize: 1024, OrderedInput: true},
+	)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), stats.Read)
+	require.Equal(t, int64(2), stats.Fixed)
+	require.Equal(t, int64(1), stats.Failed)
+	require.Equal(t, int64(1), stats.Unknown)
+	require.Len(t, stats.Groups, 2)
+	require.Equal(t, decode.RedecodeGroup{StoreKey: "bank", Reason: "old supply decoder", Fixed: 2}, stats.Groups[0])
+	require.Equal(t, "bank", stats.Groups[1].StoreKey)
+	require.Equal(t, int64(1), stats.Groups[1].Failed)
+	require.NotEqual(t, "old supply decoder", stats.Groups[1].Reason)
+	require.Contains(t, stats.Report(), "fixed: 2")
+
+	nodes, err := compact.NewSequencedIterator(nodesDir, func() *api.Node { return &api.Node{} })
+	require.NoError(t, err)
+	var fixed []string
+	for ; nodes.Valid(); err = nodes.Next() {
+		require.NoError(t, err)
+		fixed = append(fixed, string(nodes.Node.Key[1:]))
+	}
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "c"}, fixed)
+
+	errs, err := compact.NewSequencedIterator(errsDir, func() *api.DecodeError { return &api.DecodeError{} })
+	require.NoError(t, err)
+	var remaining []*api.DecodeError
+	for ; errs.Valid(); err = errs.Next() {
+		require.NoError(t, err)
+		remaining = append(remaining, errs.Node)
+	}
+	require.NoError(t, err)
+	require.Len(t, remaining, 2)
+	require.Equal(t, "b", string(remaining[0].Node.Key[1:]))
+	require.Equal(t, stats.Groups[1].Reason, decode.NormalizeReason(remaining[0].Reason))
+	// without a decoder the error is kept as it was
+	require.Equal(t, "gov decoder", remaining[1].Reason)
+	require.Equal(t, "Proposals", remaining[1].HumanPrefix)
+}
+
+func TestRedecode_CompactFails(t *testing.T) {
+	dir := t.TempDir()
+	// alternate records which now decode with records without a decoder, in incompressible amounts
+	var errs []*api.DecodeError
+	for i := 0; i < 5_000; i++ {
+		key := make([]byte, 20)
+		amount := make([]byte, 200)
+		_, err := rand.Read(key)
+		require.NoError(t, err)
+		_, err = rand.Read(amount)
+		require.NoError(t, err)
+		for j := range amount {
+			amount[j] = '0' + amount[j]%10
+		}
+		block := int64(i + 1)
+		errs = append(errs,
+			&api.DecodeError{StoreKey: "bank", HumanPrefix: "Supply", Reason: "old supply decoder", Node: &api.Node{
+				StoreKey: "bank", Key: append([]byte{0x00}, fmt.Sprintf("%x", key)...), Value: amount, Block: block}},
+			&api.DecodeError{StoreKey: "gov", HumanPrefix: "Proposals", Reason: "gov decoder", Node: &api.Node{
+				StoreKey: "gov", Key: key, Value: key, Block: block}},
+		)
+	}
+	writeDecodeErrors(t, dir, errs...)
+
+	// the error stream fails on its first segment, after the node stream wrote some
+	nodesDir := t.TempDir()
+	_, err := decode.Redecode(dir, decode.NewSDKRegistry(""),
+		&compact.StreamingContext{OutDir: nodesDir, MaxFileSize: 1024, OrderedInput: true},
+		&compact.StreamingContext{OutDir: filepath.Join(t.TempDir(), "missing"), MaxFileSize: 1024, OrderedInput: true},
+	)
+	require.ErrorIs(t, err, os.ErrNotExist)
+	entries, err := os.ReadDir(nodesDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
@@ -0,0 +1,205 @@
+// Package decode turns the raw keys and values of store writes into typed, human readable values.  Decoders are
+// registered by store key and key prefix; a write which a decoder fails on becomes an api.DecodeError.
+package decode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/compact"
+	"github.com/kocubinski/costor-api/keys"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrKey and ErrUnknownType classify the errors of decoders into DecodeError codes.  An error wrapping ErrKey or
+// keys.ErrLayout gets DECODE_ERROR_KEY, one wrapping ErrUnknownType DECODE_ERROR_UNKNOWN_TYPE, and any other error
+// DECODE_ERROR_VALUE.
+var (
+	ErrKey         = errors.New("invalid key")
+	ErrUnknownType = errors.New("unknown type")
+)
+
+// Decoder decodes the writes to one key prefix of a store.
+type Decoder struct {
+	StoreKey string
+	Prefix   []byte
+	// HumanPrefix names the prefix, such as "Balances".
+	HumanPrefix string
+	// Decode returns the human readable form of a write.  key does not include Prefix.  value is nil for deletes, of
+	// which only the key is decoded.
+	Decode func(key, value []byte) (any, error)
+}
+
+// Decoded is the human readable form of a node.
+type Decoded struct {
+	Node        *api.Node
+	StoreKey    string
+	HumanPrefix string
+	Value       any
+}
+
+// Registry selects the decoder of a node by its store key and the longest registered prefix of its key.
+type Registry struct {
+	// decoders holds the decoders of each store key, longest prefix first
+	decoders map[string][]*Decoder
+}
+
+func NewRegistry(decoders ...*Decoder) (*Registry, error) {
+	r := &Registry{decoders: make(map[string][]*Decoder)}
+	for _, d := range decoders {
+		if err := r.Register(d); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register adds a decoder.  Only one decoder may be registered for a store key and prefix.
+func (r *Registry) Register(d *Decoder) error {
+	decoders := r.decoders[d.StoreKey]
+	for _, other := range decoders {
+		if bytes.Equal(other.Prefix, d.Prefix) {
+			return fmt.Errorf("decoder for store %s prefix %X already registered as %s",
+				d.StoreKey, d.Prefix, other.HumanPrefix)
+		}
+	}
+	decoders = append(decoders, d)
+	sort.SliceStable(decoders, func(i, j int) bool { return len(decoders[i].Prefix) > len(decoders[j].Prefix) })
+	r.decoders[d.StoreKey] = decoders
+	return nil
+}
+
+// Lookup returns the decoder for a key of storeKey, or nil if none is registered.
+func (r *Registry) Lookup(storeKey string, key []byte) *Decoder {
+	for _, d := range r.decoders[storeKey] {
+		if bytes.HasPrefix(key, d.Prefix) {
+			return d
+		}
+	}
+	return nil
+}
+
+// Decode decodes node with the decoder registered for its store key and key.  It returns nil and no error if there
+// is no decoder for the node.  If the decoder fails the returned DecodeError holds node, which is not copied.
+func (r *Registry) Decode(node *api.Node) (*Decoded, *api.DecodeError) {
+	d := r.Lookup(node.StoreKey, node.Key)
+	if d == nil {
+		return nil, nil
+	}
+	var value []byte
+	if !node.Delete {
+		value = node.Value
+	}
+	decoded, err := d.Decode(node.Key[len(d.Prefix):], value)
+	if err != nil {
+		return nil, &api.DecodeError{
+			Node:        node,
+			StoreKey:    node.StoreKey,
+			HumanPrefix: d.HumanPrefix,
+			Reason:      err.Error(),
+			Code:        errorCode(err),
+		}
+	}
+	return &Decoded{Node: node, StoreKey: node.StoreKey, HumanPrefix: d.HumanPrefix, Value: decoded}, nil
+}
+
+func errorCode(err error) api.DecodeErrorCode {
+	switch {
+	case errors.Is(err, ErrKey), errors.Is(err, keys.ErrLayout):
+		return api.DecodeErrorCode_DECODE_ERROR_KEY
+	case errors.Is(err, ErrUnknownType):
+		return api.DecodeErrorCode_DECODE_ERROR_UNKNOWN_TYPE
+	}
+	return api.DecodeErrorCode_DECODE_ERROR_VALUE
+}
+
+// RouteStats counts the nodes Route handled.
+type RouteStats struct {
+	Decoded int
+	Failed  int
+	// Unknown is the number of nodes without a decoder.
+	Unknown int
+}
+
+// Route decodes every node of itr.  Decoded nodes are passed to fn, and decode errors are sent to errs, such as the
+// In of a StreamingContext writing the error stream.  Nodes are copied before they are kept, so itr may reuse them.
+// errs is not closed.
+func (r *Registry) Route(
+	itr api.NodeIterator, fn func(*Decoded) error, errs chan<- compact.Sequenced,
+) (RouteStats, error) {
+	var (
+		stats RouteStats
+		err   error
+	)
+	for ; itr.Valid(); err = itr.Next() {
+		if err != nil {
+			return stats, err
+		}
+		node := itr.GetNode()
+		decoded, decodeErr := r.Decode(node)
+		switch {
+		case decodeErr != nil:
+			decodeErr.Node = proto.Clone(node).(*api.Node)
+			errs <- decodeErr
+			stats.Failed++
+		case decoded == nil:
+			stats.Unknown++
+		default:
+			decoded.Node = proto.Clone(node).(*api.Node)
+			if err := fn(decoded); err != nil {
+				return stats, err
+			}
+			stats.Decoded++
+		}
+	}
+	return stats, err
+}
+
+// FromLayout returns a decoder for the keys of layout in storeKey.  The key is split into its parts by the layout, and
+// decodeValue builds the human readable form from the parts and the value, which is nil for deletes.
+func FromLayout(
+	storeKey string, layout *keys.Layout, decodeValue func(parts []keys.Value, value []byte) (any, error),
+) *Decoder {
+	return &Decoder{
+		StoreKey:    storeKey,
+		Prefix:      layout.Prefix,
+		HumanPrefix: layout.HumanPrefix,
+		Decode: func(key, value []byte) (any, error) {
+			parts, err := layout.DecodeParts(key)
+			if err != nil {
+				return nil, err
+			}
+			return decodeValue(parts, value)
+		},
+	}
+}
+
+// SDKDecoders returns decoders for common Cosmos SDK stores: bank balances and supply, staking validators, and
+// accounts and account numbers.  Addresses are rendered in bech32 with the human readable part hrp, such as cosmos,
+// and validator operator addresses with hrp followed by valoper; an empty hrp renders them in hex.
+func SDKDecoders(hrp string) []*Decoder {
+	valoperHRP := ""
+	if hrp != "" {
+		valoperHRP = hrp + "valoper"
+	}
+	return []*Decoder{
+		FromLayout("bank", SupplyLayout, decodeSupply),
+		FromLayout("bank", BalancesLayout(hrp), decodeBalance),
+		FromLayout("staking", ValidatorsLayout(valoperHRP), decodeValidator),
+		FromLayout("acc", AccountsLayout(hrp), decodeAccount),
+		FromLayout("acc", GlobalAccountNumberLayout, decodeGlobalAccountNumber),
+		FromLayout("acc", AccountNumbersLayout, accountNumberDecoder(hrp)),
+	}
+}
+
+// NewSDKRegistry returns a registry of SDKDecoders.
+func NewSDKRegistry(hrp string) *Registry {
+	r, err := NewRegistry(SDKDecoders(hrp)...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
@@ -0,0 +1,135 @@
+package decode
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/compact"
+)
+
+var (
+	quotedPattern = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`)
+	hexPattern    = regexp.MustCompile(`\b(?:0x)?[0-9A-Fa-f]{8,}\b`)
+	numberPattern = regexp.MustCompile(`\b-?[0-9]+\b`)
+)
+
+// NormalizeReason replaces the parts of a decode error reason which vary from record to record, quoted strings, hex
+// such as keys and hashes, and numbers, with placeholders, so that the reasons of one fault compare equal.
+func NormalizeReason(reason string) string {
+	reason = quotedPattern.ReplaceAllString(reason, "<string>")
+	reason = hexPattern.ReplaceAllString(reason, "<hex>")
+	return numberPattern.ReplaceAllString(reason, "<n>")
+}
+
+// ErrorSample is one of the errors of an ErrorGroup.
+type ErrorSample struct {
+	Block int64 `json:"block"`
+	// Key is the node's key in upper case hex.
+	Key    string `json:"key"`
+	Reason string `json:"reason"`
+}
+
+// ErrorGroup counts the decode errors of one store key, human prefix, code and normalized reason.  Errors written before
+// codes were recorded have the code DECODE_ERROR_UNSPECIFIED.
+type ErrorGroup struct {
+	StoreKey    string `json:"store_key"`
+	HumanPrefix string `json:"human_prefix"`
+	// Code is the name of the errors' DecodeErrorCode, such as DECODE_ERROR_VALUE.
+	Code string `json:"code"`
+	// Reason is the normalized reason shared by the group's errors.
+	Reason     string        `json:"reason"`
+	Count      int64         `json:"count"`
+	FirstBlock int64         `json:"first_block"`
+	LastBlock  int64         `json:"last_block"`
+	Samples    []ErrorSample `json:"samples"`
+}
+
+// ErrorReport groups the decode errors of one or more directories, largest group first.
+type ErrorReport struct {
+	Dirs   []string     `json:"dirs"`
+	Total  int64        `json:"total"`
+	Groups []ErrorGroup `json:"groups"`
+}
+
+func (r *ErrorReport) Report() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("decode errors in %s:\n", strings.Join(r.Dirs, ", ")))
+	sb.WriteString(fmt.Sprintf("total: %s\n", humanize.Comma(r.Total)))
+	for _, g := range r.Groups {
+		sb.WriteString(fmt.Sprintf("%s %s/%s %s: blocks %d-%d\n", humanize.Comma(g.Count), g.StoreKey, g.HumanPrefix,
+			strings.TrimPrefix(g.Code, "DECODE_ERROR_"), g.FirstBlock, g.LastBlock))
+		sb.WriteString(fmt.Sprintf("  reason: %s\n", g.Reason))
+		for _, s := range g.Samples {
+			sb.WriteString(fmt.Sprintf("  block %d key %s: %s\n", s.Block, s.Key, s.Reason))
+		}
+	}
+	return sb.String()
+}
+
+// AnalyzeErrors reads the decode errors of dirs into a report, keeping up to samples sample errors per group.
+func AnalyzeErrors(samples int, dirs ...string) (*ErrorReport, error) {
+	report := &ErrorReport{Dirs: dirs, Groups: []ErrorGroup{}}
+	type groupKey struct {
+		storeKey, humanPrefix string
+		code                  api.DecodeErrorCode
+		reason                string
+	}
+	groups := make(map[groupKey]*ErrorGroup)
+	for _, dir := range dirs {
+		itr, err := compact.NewSequencedIterator(dir, func() *api.DecodeError { return &api.DecodeError{} },
+			compact.WithReuseNode())
+		for ; err == nil && itr.Valid(); err = itr.Next() {
+			e := itr.Node
+			reason := NormalizeReason(e.Reason)
+			k := groupKey{storeKey: e.StoreKey, humanPrefix: e.HumanPrefix, code: e.Code, reason: reason}
+			block := e.Sequence()
+			g, ok := groups[k]
+			if !ok {
+				g = &ErrorGroup{StoreKey: e.StoreKey, HumanPrefix: e.HumanPrefix, Code: e.Code.String(), Reason: reason,
+					FirstBlock: block, LastBlock: block, Samples: []ErrorSample{}}
+				groups[k] = g
+			}
+			g.Count++
+			if block < g.FirstBlock {
+				g.FirstBlock = block
+			}
+			if block > g.LastBlock {
+				g.LastBlock = block
+			}
+			if len(g.Samples) < samples {
+				g.Samples = append(g.Samples, ErrorSample{
+					Block:  block,
+					Key:    strings.ToUpper(hex.EncodeToString(e.Node.GetKey())),
+					Reason: e.Reason,
+				})
+			}
+			report.Total++
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, g := range groups {
+		report.Groups = append(report.Groups, *g)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		switch {
+		case a.Count != b.Count:
+			return a.Count > b.Count
+		case a.StoreKey != b.StoreKey:
+			return a.StoreKey < b.StoreKey
+		case a.HumanPrefix != b.HumanPrefix:
+			return a.HumanPrefix < b.HumanPrefix
+		case a.Code != b.Code:
+			return a.Code < b.Code
+		}
+		return a.Reason < b.Reason
+	})
+	return report, nil
+}
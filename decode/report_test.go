@@ -0,0 +1,102 @@
+package decode_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/decode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeReason(t *testing.T) {
+	cases := map[string]string{
+		`supply value: invalid integer "x"`:                 `supply value: invalid integer <string>`,
+		`account value: unknown type "/other.Account"`:      `account value: unknown type <string>`,
+		`denom trace 27394FB092D2ECCD at block 12`:          `denom trace <hex> at block <n>`,
+		`invalid protobuf field 3: unexpected EOF`:          `invalid protobuf field <n>: unexpected EOF`,
+		`cosmos.bank.v1beta1.Metadata: proto: cannot parse`: `cosmos.bank.v1beta1.Metadata: proto: cannot parse`,
+	}
+	for reason, expected := range cases {
+		require.Equal(t, expected, decode.NormalizeReason(reason))
+	}
+}
+
+func TestAnalyzeErrors(t *testing.T) {
+	r := decode.NewSDKRegistry("")
+	var errs []*api.DecodeError
+	for block, value := range []string{"x", "1", "y", "1", "z", "-"} {
+		node := &api.Node{StoreKey: "bank", Key: []byte{0x00, 'a' + byte(block)}, Value: []byte(value),
+			Block: int64(block + 1)}
+		if _, decodeErr := r.Decode(node); decodeErr != nil {
+			errs = append(errs, decodeErr)
+		}
+	}
+	_, decodeErr := r.Decode(&api.Node{StoreKey: "bank", Key: []byte{0x02, 0x10}, Block: 4})
+	require.NotNil(t, decodeErr)
+	errs = append(errs, decodeErr)
+	dir := t.TempDir()
+	writeDecodeErrors(t, dir, errs...)
+
+	// errors without a code, written before codes were recorded, are grouped by reason too
+	legacyDir := t.TempDir()
+	writeDecodeErrors(t, legacyDir,
+		&api.DecodeError{StoreKey: "gov", HumanPrefix: "Proposals", Reason: "proposal 1: bad status 9",
+			Node: &api.Node{StoreKey: "gov", Key: []byte{0x00, 0x01}, Block: 7}},
+		&api.DecodeError{StoreKey: "gov", HumanPrefix: "Proposals", Reason: "proposal 2: bad status 10",
+			Node: &api.Node{StoreKey: "gov", Key: []byte{0x00, 0x02}, Block: 8}},
+		&api.DecodeError{StoreKey: "gov", HumanPrefix: "Proposals", Reason: "proposal 3: missing title",
+			Node: &api.Node{StoreKey: "gov", Key: []byte{0x00, 0x03}, Block: 9}},
+	)
+
+	report, err := decode.AnalyzeErrors(2, dir, legacyDir)
+	require.NoError(t, err)
+	require.Equal(t, int64(8), report.Total)
+	require.Len(t, report.Groups, 5)
+
+	supply := report.Groups[0]
+	require.Equal(t, "bank", supply.StoreKey)
+	require.Equal(t, "Supply", supply.HumanPrefix)
+	require.Equal(t, "DECODE_ERROR_VALUE", supply.Code)
+	require.Equal(t, "supply value: invalid integer <string>", supply.Reason)
+	require.Equal(t, int64(3), supply.Count)
+	require.Equal(t, int64(1), supply.FirstBlock)
+	require.Equal(t, int64(5), supply.LastBlock)
+	require.Equal(t, []decode.ErrorSample{
+		{Block: 1, Key: "0061", Reason: `supply value: invalid integer "x"`},
+		{Block: 3, Key: "0063", Reason: `supply value: invalid integer "y"`},
+	}, supply.Samples)
+
+	legacy := report.Groups[1]
+	require.Equal(t, "gov", legacy.StoreKey)
+	require.Equal(t, "DECODE_ERROR_UNSPECIFIED", legacy.Code)
+	require.Equal(t, "proposal <n>: bad status <n>", legacy.Reason)
+	require.Equal(t, int64(2), legacy.Count)
+	require.Equal(t, int64(7), legacy.FirstBlock)
+	require.Equal(t, int64(8), legacy.LastBlock)
+
+	balances := report.Groups[2]
+	require.Equal(t, "Balances", balances.HumanPrefix)
+	require.Equal(t, "DECODE_ERROR_KEY", balances.Code)
+	require.Equal(t, int64(1), balances.Count)
+
+	// a different fault with the same code is a group of its own
+	empty := report.Groups[3]
+	require.Equal(t, "Supply", empty.HumanPrefix)
+	require.Equal(t, "DECODE_ERROR_VALUE", empty.Code)
+	require.Equal(t, "supply value: empty integer", empty.Reason)
+	require.Equal(t, int64(1), empty.Count)
+	require.Equal(t, int64(6), empty.FirstBlock)
+
+	require.Equal(t, "proposal <n>: missing title", report.Groups[4].Reason)
+
+	text := report.Report()
+	require.Contains(t, text, "total: 8")
+	require.Contains(t, text, "3 bank/Supply VALUE: blocks 1-5")
+
+	bz, err := json.Marshal(report)
+	require.NoError(t, err)
+	var roundTrip decode.ErrorReport
+	require.NoError(t, json.Unmarshal(bz, &roundTrip))
+	require.Equal(t, *report, roundTrip)
+}
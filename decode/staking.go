@@ -0,0 +1,77 @@
+package decode
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kocubinski/costor-api/keys"
+)
+
+// ValidatorsLayout is the layout of the staking store's validator keys: 0x21 followed by the length prefixed
+// operator address, rendered with hrp.
+func ValidatorsLayout(hrp string) *keys.Layout {
+	return keys.NewLayout("Validators", []byte{0x21}, keys.Field("operator", keys.Address(hrp)))
+}
+
+// Validator is a staking validator, keyed by operator address.
+type Validator struct {
+	Operator        string `json:"operator"`
+	OperatorAddress string `json:"operator_address,omitempty"`
+	Moniker         string `json:"moniker,omitempty"`
+	Jailed          bool   `json:"jailed,omitempty"`
+	Status          string `json:"status,omitempty"`
+	Tokens          string `json:"tokens,omitempty"`
+	DelegatorShares string `json:"delegator_shares,omitempty"`
+}
+
+var bondStatus = map[uint64]string{
+	0: "BOND_STATUS_UNSPECIFIED",
+	1: "BOND_STATUS_UNBONDED",
+	2: "BOND_STATUS_UNBONDING",
+	3: "BOND_STATUS_BONDED",
+}
+
+// decodeValidator decodes a cosmos.staking.v1beta1.Validator.
+func decodeValidator(parts []keys.Value, value []byte) (any, error) {
+	v := &Validator{Operator: parts[0].Text}
+	if value == nil {
+		return v, nil
+	}
+	fs, err := fields(value)
+	if err != nil {
+		return nil, fmt.Errorf("validator value: %w", err)
+	}
+	for _, f := range fs {
+		switch f.num {
+		case 1:
+			v.OperatorAddress = string(f.bytes)
+		case 3:
+			v.Jailed = f.varint != 0
+		case 4:
+			status, ok := bondStatus[f.varint]
+			if !ok {
+				return nil, fmt.Errorf("validator value: unknown bond status %d", f.varint)
+			}
+			v.Status = status
+		case 5:
+			v.Tokens = string(f.bytes)
+		case 6:
+			v.DelegatorShares = string(f.bytes)
+		case 7:
+			// Description, of which moniker = 1
+			desc, err := fields(f.bytes)
+			if err != nil {
+				return nil, fmt.Errorf("validator description: %w", err)
+			}
+			for _, df := range desc {
+				if df.num == 1 {
+					v.Moniker = string(df.bytes)
+				}
+			}
+		}
+	}
+	if v.OperatorAddress == "" {
+		return nil, errors.New("validator value: missing operator address")
+	}
+	return v, nil
+}
@@ -0,0 +1,69 @@
+package decode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The SDK's values are decoded straight from the protobuf wire format, field by field, so that the repo does not
+// depend on the SDK's generated types.
+
+// field is one field of a protobuf message.  varint holds the value of varint and fixed width fields, bytes the value
+// of length delimited fields.
+type field struct {
+	num    protowire.Number
+	typ    protowire.Type
+	varint uint64
+	bytes  []byte
+}
+
+// fields parses the fields of a protobuf message, in wire order.
+func fields(bz []byte) ([]field, error) {
+	var fs []field
+	for len(bz) > 0 {
+		num, typ, n := protowire.ConsumeTag(bz)
+		if n < 0 {
+			return nil, fmt.Errorf("invalid protobuf tag: %w", protowire.ParseError(n))
+		}
+		bz = bz[n:]
+		f := field{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.varint, n = protowire.ConsumeVarint(bz)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(bz)
+			f.varint = uint64(v)
+		case protowire.Fixed64Type:
+			f.varint, n = protowire.ConsumeFixed64(bz)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(bz)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, bz)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("invalid protobuf field %d: %w", num, protowire.ParseError(n))
+		}
+		bz = bz[n:]
+		fs = append(fs, f)
+	}
+	return fs, nil
+}
+
+// parseInt checks that bz is the text form of an integer, as the SDK's math.Int marshals itself.
+func parseInt(bz []byte) (string, error) {
+	s := string(bz)
+	digits := strings.TrimPrefix(s, "-")
+	if digits == "" {
+		return "", errors.New("empty integer")
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return "", fmt.Errorf("invalid integer %q", s)
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,20 @@
+package api
+
+func (e *Envelope) Sequence() int64 {
+	return e.Block
+}
+
+// NodeEnvelope wraps n in an Envelope sequenced by its block.
+func NodeEnvelope(n *Node) *Envelope {
+	return &Envelope{Block: n.Block, Record: &Envelope_Node{Node: n}}
+}
+
+// DecodeErrorEnvelope wraps e in an Envelope sequenced by the block of its node.
+func DecodeErrorEnvelope(e *DecodeError) *Envelope {
+	return &Envelope{Block: e.Sequence(), Record: &Envelope_DecodeError{DecodeError: e}}
+}
+
+// BlockMetadataEnvelope wraps m in an Envelope sequenced by its block.
+func BlockMetadataEnvelope(m *BlockMetadata) *Envelope {
+	return &Envelope{Block: m.Block, Record: &Envelope_BlockMetadata{BlockMetadata: m}}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrHashMismatch is returned when a node's Hash does not match its leaf hash.
+var ErrHashMismatch = errors.New("leaf hash mismatch")
+
+// LeafHash returns the hash IAVL computes for a leaf node holding key and value, written at version.  It is the
+// sha256 of the leaf's height (0), size (1) and version as signed varints, followed by the key and the sha256 of the
+// value, each prefixed with its length as an unsigned varint.
+func LeafHash(key, value []byte, version int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	h := sha256.New()
+	n := binary.PutVarint(buf[:], 0)
+	h.Write(buf[:n])
+	n = binary.PutVarint(buf[:], 1)
+	h.Write(buf[:n])
+	n = binary.PutVarint(buf[:], version)
+	h.Write(buf[:n])
+	n = binary.PutUvarint(buf[:], uint64(len(key)))
+	h.Write(buf[:n])
+	h.Write(key)
+	valueHash := sha256.Sum256(value)
+	n = binary.PutUvarint(buf[:], uint64(len(valueHash)))
+	h.Write(buf[:n])
+	h.Write(valueHash[:])
+	return h.Sum(nil)
+}
+
+// LeafHash returns the IAVL hash of the leaf the node is written to, at version Block.  Deletes have no leaf and
+// return nil.
+func (n *Node) LeafHash() []byte {
+	if n.Delete {
+		return nil
+	}
+	return LeafHash(n.Key, n.Value, n.Block)
+}
+
+// FillHash sets Hash to the node's leaf hash.
+func (n *Node) FillHash() {
+	n.Hash = n.LeafHash()
+}
+
+// VerifyHash checks a node's Hash against its leaf hash.  Nodes without a Hash pass.
+func (n *Node) VerifyHash() error {
+	if len(n.Hash) == 0 {
+		return nil
+	}
+	if !bytes.Equal(n.Hash, n.LeafHash()) {
+		return fmt.Errorf("%w: store %s key %X block %d", ErrHashMismatch, n.StoreKey, n.Key, n.Block)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package iavl
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+
+	api "github.com/kocubinski/costor-api"
+)
+
+// node is an immutable IAVL node.  Once built into a tree a node is never modified, except to cache its hash, so it
+// may be shared by any number of tree versions.
+type node struct {
+	// for an inner node, the smallest key in its right subtree
+	key     []byte
+	value   []byte
+	version int64
+	height  int8
+	size    int64
+	left    *node
+	right   *node
+	hash    []byte
+}
+
+func newLeaf(key, value []byte, version int64) *node {
+	return &node{key: key, value: value, version: version, size: 1}
+}
+
+func (n *node) isLeaf() bool {
+	return n.height == 0
+}
+
+// clone returns a copy of n at version, without its cached hash.
+func (n *node) clone(version int64) *node {
+	return &node{
+		key:     n.key,
+		value:   n.value,
+		version: version,
+		height:  n.height,
+		size:    n.size,
+		left:    n.left,
+		right:   n.right,
+	}
+}
+
+func (n *node) calcHeightAndSize() {
+	n.height = n.left.height
+	if n.right.height > n.height {
+		n.height = n.right.height
+	}
+	n.height++
+	n.size = n.left.size + n.right.size
+}
+
+func (n *node) balance() int {
+	return int(n.left.height) - int(n.right.height)
+}
+
+// computeHash returns the node's hash, computing and caching it and any uncached child hashes first.  An inner node
+// hashes its height, size and version as signed varints followed by the length prefixed hashes of its children; see
+// api.LeafHash for leaves.
+func (n *node) computeHash() []byte {
+	if n.hash != nil {
+		return n.hash
+	}
+	if n.isLeaf() {
+		n.hash = api.LeafHash(n.key, n.value, n.version)
+		return n.hash
+	}
+	var buf [binary.MaxVarintLen64]byte
+	h := sha256.New()
+	i := binary.PutVarint(buf[:], int64(n.height))
+	h.Write(buf[:i])
+	i = binary.PutVarint(buf[:], n.size)
+	h.Write(buf[:i])
+	i = binary.PutVarint(buf[:], n.version)
+	h.Write(buf[:i])
+	for _, child := range []*node{n.left, n.right} {
+		childHash := child.computeHash()
+		i = binary.PutUvarint(buf[:], uint64(len(childHash)))
+		h.Write(buf[:i])
+		h.Write(childHash)
+	}
+	n.hash = h.Sum(nil)
+	return n.hash
+}
@@ -0,0 +1,331 @@
+package iavl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	api "github.com/kocubinski/costor-api"
+)
+
+var (
+	// ErrKeyNotFound is returned when a membership proof is requested for a key which is not set.
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrKeyExists is returned when a non-membership proof is requested for a key which is set.
+	ErrKeyExists = errors.New("key exists")
+	// ErrInvalidProof is returned by the verifiers when a proof does not prove what was asked of it.
+	ErrInvalidProof = errors.New("invalid proof")
+)
+
+// The proofs built here follow the ICS-23 IAVL spec:
+//   - a leaf is hashed as sha256(prefix || uvarint(len(key)) || key || uvarint(32) || sha256(value)), where the prefix
+//     is the leaf's height (0), size (1) and version as signed varints
+//   - an inner node is hashed as sha256(prefix || child || suffix), where prefix and suffix carry the node's height,
+//     size and version and the length prefixed hash of the sibling, so that the child's hash and its length prefix are
+//     the only bytes a verifier supplies
+
+// GetProof returns a membership proof if key is set in the tree, and a non-membership proof otherwise.
+func (t *Tree) GetProof(key []byte) (*api.CommitmentProof, error) {
+	if t.Has(key) {
+		return t.GetMembershipProof(key)
+	}
+	return t.GetNonMembershipProof(key)
+}
+
+// GetMembershipProof returns a proof that key is set in the tree.
+func (t *Tree) GetMembershipProof(key []byte) (*api.CommitmentProof, error) {
+	exist, err := t.existenceProof(key)
+	if err != nil {
+		return nil, err
+	}
+	return &api.CommitmentProof{Proof: &api.CommitmentProof_Exist{Exist: exist}}, nil
+}
+
+// GetNonMembershipProof returns a proof that key is not set in the tree, made of membership proofs for the keys
+// immediately before and after it.
+func (t *Tree) GetNonMembershipProof(key []byte) (*api.CommitmentProof, error) {
+	if t == nil || t.root == nil {
+		return nil, fmt.Errorf("%w: cannot prove absence from an empty tree", ErrInvalidProof)
+	}
+	left, right, exists := t.neighbours(key)
+	if exists {
+		return nil, fmt.Errorf("%w: %X", ErrKeyExists, key)
+	}
+	nonexist := &api.NonExistenceProof{Key: key}
+	var err error
+	if left != nil {
+		if nonexist.Left, err = t.existenceProof(left); err != nil {
+			return nil, err
+		}
+	}
+	if right != nil {
+		if nonexist.Right, err = t.existenceProof(right); err != nil {
+			return nil, err
+		}
+	}
+	return &api.CommitmentProof{Proof: &api.CommitmentProof_Nonexist{Nonexist: nonexist}}, nil
+}
+
+// neighbours returns the largest key below key and the smallest key above it, each nil if there is none.  exists is
+// true if key itself is set.
+func (t *Tree) neighbours(key []byte) (left, right []byte, exists bool) {
+	var lastLeft *node
+	n := t.root
+	for !n.isLeaf() {
+		if bytes.Compare(key, n.key) < 0 {
+			// n.key is the smallest key of the right subtree
+			right = n.key
+			n = n.left
+		} else {
+			lastLeft = n.left
+			n = n.right
+		}
+	}
+	switch bytes.Compare(n.key, key) {
+	case 0:
+		return nil, nil, true
+	case -1:
+		return n.key, right, false
+	}
+	if lastLeft != nil {
+		for !lastLeft.isLeaf() {
+			lastLeft = lastLeft.right
+		}
+		left = lastLeft.key
+	}
+	return left, n.key, false
+}
+
+func (t *Tree) existenceProof(key []byte) (*api.ExistenceProof, error) {
+	if t == nil || t.root == nil {
+		return nil, fmt.Errorf("%w: %X", ErrKeyNotFound, key)
+	}
+	var path []*api.InnerOp
+	n := t.root
+	for !n.isLeaf() {
+		if bytes.Compare(key, n.key) < 0 {
+			path = append(path, innerOp(n, nil, n.right.computeHash()))
+			n = n.left
+		} else {
+			path = append(path, innerOp(n, n.left.computeHash(), nil))
+			n = n.right
+		}
+	}
+	if !bytes.Equal(n.key, key) {
+		return nil, fmt.Errorf("%w: %X", ErrKeyNotFound, key)
+	}
+	// the path runs from the leaf up
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return &api.ExistenceProof{
+		Key:   n.key,
+		Value: n.value,
+		Leaf: &api.LeafOp{
+			Hash:         api.HashOp_SHA256,
+			PrehashKey:   api.HashOp_NO_HASH,
+			PrehashValue: api.HashOp_SHA256,
+			Length:       api.LengthOp_VAR_PROTO,
+			Prefix:       nodePrefix(0, 1, n.version),
+		},
+		Path: path,
+	}, nil
+}
+
+// innerOp returns the step from a child of n to n.  Exactly one of leftHash and rightHash is set: the hash of the
+// sibling of the child being proven.
+func innerOp(n *node, leftHash, rightHash []byte) *api.InnerOp {
+	prefix := nodePrefix(n.height, n.size, n.version)
+	var suffix []byte
+	if leftHash != nil {
+		prefix = binary.AppendUvarint(prefix, uint64(len(leftHash)))
+		prefix = append(prefix, leftHash...)
+		prefix = binary.AppendUvarint(prefix, sha256.Size)
+	} else {
+		prefix = binary.AppendUvarint(prefix, sha256.Size)
+		suffix = binary.AppendUvarint(suffix, uint64(len(rightHash)))
+		suffix = append(suffix, rightHash...)
+	}
+	return &api.InnerOp{Hash: api.HashOp_SHA256, Prefix: prefix, Suffix: suffix}
+}
+
+func nodePrefix(height int8, size, version int64) []byte {
+	prefix := binary.AppendVarint(nil, int64(height))
+	prefix = binary.AppendVarint(prefix, size)
+	return binary.AppendVarint(prefix, version)
+}
+
+// VerifyMembership checks that proof proves key is set to value in the tree with the root hash root.
+func VerifyMembership(root []byte, proof *api.CommitmentProof, key, value []byte) error {
+	exist := proof.GetExist()
+	if exist == nil {
+		return fmt.Errorf("%w: not a membership proof", ErrInvalidProof)
+	}
+	if !bytes.Equal(exist.Key, key) {
+		return fmt.Errorf("%w: proves key %X, not %X", ErrInvalidProof, exist.Key, key)
+	}
+	if !bytes.Equal(exist.Value, value) {
+		return fmt.Errorf("%w: proves a different value for key %X", ErrInvalidProof, key)
+	}
+	return verifyExistence(root, exist)
+}
+
+// VerifyNonMembership checks that proof proves key is not set in the tree with the root hash root.
+func VerifyNonMembership(root []byte, proof *api.CommitmentProof, key []byte) error {
+	nonexist := proof.GetNonexist()
+	if nonexist == nil {
+		return fmt.Errorf("%w: not a non-membership proof", ErrInvalidProof)
+	}
+	if !bytes.Equal(nonexist.Key, key) {
+		return fmt.Errorf("%w: proves absence of key %X, not %X", ErrInvalidProof, nonexist.Key, key)
+	}
+	left, right := nonexist.Left, nonexist.Right
+	if left == nil && right == nil {
+		return fmt.Errorf("%w: no neighbours", ErrInvalidProof)
+	}
+	if left != nil {
+		if err := verifyExistence(root, left); err != nil {
+			return err
+		}
+		if bytes.Compare(left.Key, key) >= 0 {
+			return fmt.Errorf("%w: left neighbour %X is not below %X", ErrInvalidProof, left.Key, key)
+		}
+	}
+	if right != nil {
+		if err := verifyExistence(root, right); err != nil {
+			return err
+		}
+		if bytes.Compare(right.Key, key) <= 0 {
+			return fmt.Errorf("%w: right neighbour %X is not above %X", ErrInvalidProof, right.Key, key)
+		}
+	}
+
+	switch {
+	case left == nil:
+		if !onEdge(right.Path, leftChild) {
+			return fmt.Errorf("%w: right neighbour %X is not the first key", ErrInvalidProof, right.Key)
+		}
+	case right == nil:
+		if !onEdge(left.Path, rightChild) {
+			return fmt.Errorf("%w: left neighbour %X is not the last key", ErrInvalidProof, left.Key)
+		}
+	default:
+		if !adjacent(left.Path, right.Path) {
+			return fmt.Errorf("%w: %X and %X are not adjacent", ErrInvalidProof, left.Key, right.Key)
+		}
+	}
+	return nil
+}
+
+// verifyExistence checks that proof hashes to root.
+func verifyExistence(root []byte, proof *api.ExistenceProof) error {
+	hash, err := leafOpHash(proof)
+	if err != nil {
+		return err
+	}
+	for i, op := range proof.Path {
+		if _, err := childSide(op); err != nil {
+			return fmt.Errorf("step %d: %w", i, err)
+		}
+		h := sha256.New()
+		h.Write(op.Prefix)
+		h.Write(hash)
+		h.Write(op.Suffix)
+		hash = h.Sum(nil)
+	}
+	if !bytes.Equal(hash, root) {
+		return fmt.Errorf("%w: key %X computes root %X, not %X", ErrInvalidProof, proof.Key, hash, root)
+	}
+	return nil
+}
+
+func leafOpHash(proof *api.ExistenceProof) ([]byte, error) {
+	leaf := proof.Leaf
+	if leaf == nil || leaf.Hash != api.HashOp_SHA256 || leaf.PrehashKey != api.HashOp_NO_HASH ||
+		leaf.PrehashValue != api.HashOp_SHA256 || leaf.Length != api.LengthOp_VAR_PROTO {
+		return nil, fmt.Errorf("%w: leaf op is not IAVL's", ErrInvalidProof)
+	}
+	height, size, version, rest, ok := parsePrefix(leaf.Prefix)
+	if !ok || height != 0 || size != 1 || version < 0 || len(rest) != 0 {
+		return nil, fmt.Errorf("%w: leaf prefix %X", ErrInvalidProof, leaf.Prefix)
+	}
+	if len(proof.Key) == 0 {
+		return nil, fmt.Errorf("%w: empty key", ErrInvalidProof)
+	}
+	return api.LeafHash(proof.Key, proof.Value, version), nil
+}
+
+type side int
+
+const (
+	leftChild side = iota
+	rightChild
+)
+
+// childSide checks that op is an IAVL inner node step and returns which child of the node it hashes.
+func childSide(op *api.InnerOp) (side, error) {
+	if op.Hash != api.HashOp_SHA256 {
+		return 0, fmt.Errorf("%w: inner op hash %s", ErrInvalidProof, op.Hash)
+	}
+	height, size, _, rest, ok := parsePrefix(op.Prefix)
+	if !ok || height <= 0 || size < 2 {
+		return 0, fmt.Errorf("%w: inner prefix %X", ErrInvalidProof, op.Prefix)
+	}
+	switch {
+	case len(rest) == 1 && rest[0] == sha256.Size &&
+		len(op.Suffix) == 1+sha256.Size && op.Suffix[0] == sha256.Size:
+		return leftChild, nil
+	case len(rest) == 2+sha256.Size && rest[0] == sha256.Size && rest[1+sha256.Size] == sha256.Size &&
+		len(op.Suffix) == 0:
+		return rightChild, nil
+	}
+	return 0, fmt.Errorf("%w: inner op does not hold one sibling hash", ErrInvalidProof)
+}
+
+// parsePrefix reads the height, size and version varints which start every IAVL node prefix.
+func parsePrefix(prefix []byte) (height, size, version int64, rest []byte, ok bool) {
+	var fields [3]int64
+	for i := range fields {
+		v, n := binary.Varint(prefix)
+		if n <= 0 {
+			return 0, 0, 0, nil, false
+		}
+		fields[i] = v
+		prefix = prefix[n:]
+	}
+	return fields[0], fields[1], fields[2], prefix, true
+}
+
+// onEdge returns true if every step of path is from a child on side s, which makes its leaf the first (leftChild) or
+// last (rightChild) key of the tree.
+func onEdge(path []*api.InnerOp, s side) bool {
+	for _, op := range path {
+		if cs, err := childSide(op); err != nil || cs != s {
+			return false
+		}
+	}
+	return true
+}
+
+// adjacent returns true if the leaves of the paths left and right, both verified against the same root, are
+// neighbours.  Equal steps from the root down are the same node, so the first unequal steps are the node where the
+// paths split; below it, left must only go right and right must only go left.
+func adjacent(left, right []*api.InnerOp) bool {
+	l, r := len(left)-1, len(right)-1
+	for l >= 0 && r >= 0 && bytes.Equal(left[l].Prefix, right[r].Prefix) && bytes.Equal(left[l].Suffix, right[r].Suffix) {
+		l--
+		r--
+	}
+	if l < 0 || r < 0 {
+		return false
+	}
+	if ls, err := childSide(left[l]); err != nil || ls != leftChild {
+		return false
+	}
+	if rs, err := childSide(right[r]); err != nil || rs != rightChild {
+		return false
+	}
+	return onEdge(left[:l], rightChild) && onEdge(right[:r], leftChild)
+}
@@ -0,0 +1,142 @@
+package iavl_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/iavl"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestProofs(t *testing.T) {
+	var tree *iavl.Tree
+	for i := 0; i < 100; i++ {
+		tree, _ = tree.Set([]byte(fmt.Sprintf("key-%03d", i*2)), []byte(fmt.Sprintf("value-%d", i)), int64(i/10+1))
+	}
+	root := tree.Hash()
+
+	for i := -1; i <= 200; i++ {
+		key := []byte(fmt.Sprintf("key-%03d", i))
+		proof, err := tree.GetProof(key)
+		require.NoError(t, err)
+		if tree.Has(key) {
+			value := tree.Get(key)
+			require.NoError(t, iavl.VerifyMembership(root, proof, key, value))
+			require.ErrorIs(t, iavl.VerifyMembership(root, proof, key, []byte("other")), iavl.ErrInvalidProof)
+			require.ErrorIs(t, iavl.VerifyNonMembership(root, proof, key), iavl.ErrInvalidProof)
+		} else {
+			require.NoError(t, iavl.VerifyNonMembership(root, proof, key))
+			require.ErrorIs(t, iavl.VerifyMembership(root, proof, key, nil), iavl.ErrInvalidProof)
+		}
+	}
+
+	_, err := tree.GetMembershipProof([]byte("key-001"))
+	require.ErrorIs(t, err, iavl.ErrKeyNotFound)
+	_, err = tree.GetNonMembershipProof([]byte("key-002"))
+	require.ErrorIs(t, err, iavl.ErrKeyExists)
+
+	// a tampered proof no longer hashes to the root
+	proof, err := tree.GetMembershipProof([]byte("key-010"))
+	require.NoError(t, err)
+	tampered := proto.Clone(proof).(*api.CommitmentProof)
+	tampered.GetExist().Value = []byte("forged")
+	require.ErrorIs(t, iavl.VerifyMembership(root, tampered, []byte("key-010"), []byte("forged")), iavl.ErrInvalidProof)
+	require.ErrorIs(t, iavl.VerifyMembership(emptyRoot(), proof, []byte("key-010"), []byte("value-5")),
+		iavl.ErrInvalidProof)
+
+	// neighbours which are valid members but not adjacent do not prove absence
+	left, err := tree.GetMembershipProof([]byte("key-010"))
+	require.NoError(t, err)
+	right, err := tree.GetMembershipProof([]byte("key-014"))
+	require.NoError(t, err)
+	gap := &api.CommitmentProof{Proof: &api.CommitmentProof_Nonexist{Nonexist: &api.NonExistenceProof{
+		Key:   []byte("key-011"),
+		Left:  left.GetExist(),
+		Right: right.GetExist(),
+	}}}
+	require.ErrorIs(t, iavl.VerifyNonMembership(root, gap, []byte("key-011")), iavl.ErrInvalidProof)
+	gap.GetNonexist().Left = nil
+	require.ErrorIs(t, iavl.VerifyNonMembership(root, gap, []byte("key-011")), iavl.ErrInvalidProof)
+}
+
+func TestProofs_EmptyValue(t *testing.T) {
+	var tree *iavl.Tree
+	tree, _ = tree.Set([]byte("a"), []byte("1"), 1)
+	// a protobuf round trip turns an empty value into nil
+	tree, _ = tree.Set([]byte("b"), nil, 1)
+	tree, _ = tree.Set([]byte("c"), []byte("3"), 1)
+	root := tree.Hash()
+
+	require.Nil(t, tree.Get([]byte("b")))
+	require.True(t, tree.Has([]byte("b")))
+	require.False(t, tree.Has([]byte("bb")))
+	proof, err := tree.GetProof([]byte("b"))
+	require.NoError(t, err)
+	require.NotNil(t, proof.GetExist())
+	require.NoError(t, iavl.VerifyMembership(root, proof, []byte("b"), nil))
+	require.ErrorIs(t, iavl.VerifyNonMembership(root, proof, []byte("b")), iavl.ErrInvalidProof)
+
+	proof, err = tree.GetProof([]byte("bb"))
+	require.NoError(t, err)
+	require.NoError(t, iavl.VerifyNonMembership(root, proof, []byte("bb")))
+}
+
+func TestProofs_History(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	replayer := iavl.NewReplayer()
+	replayer.KeepHistory = true
+	roots := map[int64][]byte{}
+	values := map[int64]map[string][]byte{}
+	state := map[string][]byte{}
+	for version := int64(1); version <= 50; version++ {
+		cs := &api.Changeset{Version: version}
+		if version%5 != 0 {
+			for i := 0; i < 5; i++ {
+				key := fmt.Sprintf("%02d", r.Intn(30))
+				node := &api.Node{StoreKey: "bank", Key: []byte(key), Delete: r.Intn(4) == 0, Block: version}
+				if node.Delete {
+					delete(state, key)
+				} else {
+					node.Value = []byte(fmt.Sprintf("%d", r.Int()))
+					state[key] = node.Value
+				}
+				cs.Nodes = append(cs.Nodes, node)
+			}
+		}
+		require.NoError(t, replayer.Apply(cs))
+		roots[version] = replayer.Trees["bank"].Hash()
+		values[version] = map[string][]byte{}
+		for k, v := range state {
+			values[version][k] = v
+		}
+	}
+
+	_, ok := replayer.TreeAt("bank", 51)
+	require.False(t, ok)
+	for version := int64(1); version <= 50; version++ {
+		tree, ok := replayer.TreeAt("bank", version)
+		require.True(t, ok)
+		require.Equal(t, roots[version], tree.Hash(), "version %d", version)
+		for i := 0; i < 30; i++ {
+			key := []byte(fmt.Sprintf("%02d", i))
+			proof, err := tree.GetProof(key)
+			require.NoError(t, err)
+			if value, ok := values[version][string(key)]; ok {
+				require.NoError(t, iavl.VerifyMembership(roots[version], proof, key, value))
+			} else {
+				require.NoError(t, iavl.VerifyNonMembership(roots[version], proof, key))
+			}
+		}
+	}
+	tree, ok := replayer.TreeAt("staking", 10)
+	require.True(t, ok)
+	require.Equal(t, int64(0), tree.Size())
+}
+
+func emptyRoot() []byte {
+	var tree *iavl.Tree
+	return tree.Hash()
+}
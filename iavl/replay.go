@@ -0,0 +1,164 @@
+package iavl
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/compact"
+)
+
+// Replayer applies changesets, version by version, to one Tree per store.
+type Replayer struct {
+	// Trees holds the latest version of each store's tree, by store key.
+	Trees map[string]*Tree
+	// Version is the version of the last changeset applied.
+	Version int64
+	// KeepHistory retains the tree of every store at every version applied, so that TreeAt can return past state.
+	// Unchanged nodes are shared between versions, so each version costs only the nodes its writes created.
+	KeepHistory bool
+
+	history map[string][]versionedTree
+}
+
+type versionedTree struct {
+	version int64
+	tree    *Tree
+}
+
+func NewReplayer() *Replayer {
+	return &Replayer{Trees: make(map[string]*Tree)}
+}
+
+// Apply writes the nodes of a changeset at its version.  Nodes are applied in order; deleting a key which is not set
+// is a no-op, as it is in iavl.
+func (r *Replayer) Apply(cs *api.Changeset) error {
+	return r.apply(cs.Version, cs.Iterator())
+}
+
+func (r *Replayer) apply(version int64, nodes api.NodeIterator) error {
+	var err error
+	for ; nodes.Valid(); err = nodes.Next() {
+		if err != nil {
+			return err
+		}
+		node := nodes.GetNode()
+		tree := r.Trees[node.StoreKey]
+		if node.Delete {
+			tree, _ = tree.Remove(node.Key, version)
+		} else {
+			tree, _ = tree.Set(node.Key, node.Value, version)
+		}
+		r.Trees[node.StoreKey] = tree
+	}
+	if err != nil {
+		return err
+	}
+	r.Version = version
+	if r.KeepHistory {
+		r.record(version)
+	}
+	return nil
+}
+
+// record appends the tree of every store which changed at version to its history.
+func (r *Replayer) record(version int64) {
+	if r.history == nil {
+		r.history = make(map[string][]versionedTree)
+	}
+	for storeKey, tree := range r.Trees {
+		h := r.history[storeKey]
+		if n := len(h); n > 0 && h[n-1].tree == tree {
+			continue
+		}
+		r.history[storeKey] = append(h, versionedTree{version: version, tree: tree})
+	}
+}
+
+// TreeAt returns the tree of storeKey as of version, which is the tree left by the last changeset at or below version
+// which wrote to the store.  ok is false if version is later than the last version applied, or is not the current
+// version and history is not kept.
+func (r *Replayer) TreeAt(storeKey string, version int64) (tree *Tree, ok bool) {
+	switch {
+	case version > r.Version:
+		return nil, false
+	case version == r.Version:
+		return r.Trees[storeKey], true
+	case !r.KeepHistory:
+		return nil, false
+	}
+	h := r.history[storeKey]
+	i := sort.Search(len(h), func(i int) bool { return h[i].version > version })
+	if i == 0 {
+		// the store had not been written to yet
+		return nil, true
+	}
+	return h[i-1].tree, true
+}
+
+// RootHashes returns the current root hash of every store.
+func (r *Replayer) RootHashes() map[string][]byte {
+	hashes := make(map[string][]byte, len(r.Trees))
+	for storeKey, tree := range r.Trees {
+		hashes[storeKey] = tree.Hash()
+	}
+	return hashes
+}
+
+// Replay applies every changeset of src and calls fn with the root hash of each store after each version.  Replay
+// stops with the first error returned by fn.
+func (r *Replayer) Replay(src compact.ChangesetSource, fn func(version int64, roots map[string][]byte) error) error {
+	var err error
+	for ; src.Valid(); err = src.Next() {
+		if err != nil {
+			return err
+		}
+		if err := r.apply(src.Version(), src.Nodes()); err != nil {
+			return err
+		}
+		if err := fn(r.Version, r.RootHashes()); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// Mismatch is a store whose replayed root hash differs from the one the chain committed.
+type Mismatch struct {
+	Version  int64
+	StoreKey string
+	Expected []byte
+	Actual   []byte
+}
+
+// FindMismatch replays src until a store's root hash differs from expected(storeKey, version), which returns the root
+// hash the chain committed and false if it is not known.  It returns nil if every known root hash matched.  The first
+// mismatch is the first version at which a write was dropped, added or reordered.
+func (r *Replayer) FindMismatch(
+	src compact.ChangesetSource, expected func(storeKey string, version int64) ([]byte, bool),
+) (*Mismatch, error) {
+	var mismatch *Mismatch
+	err := r.Replay(src, func(version int64, roots map[string][]byte) error {
+		storeKeys := make([]string, 0, len(roots))
+		for storeKey := range roots {
+			storeKeys = append(storeKeys, storeKey)
+		}
+		sort.Strings(storeKeys)
+		for _, storeKey := range storeKeys {
+			want, ok := expected(storeKey, version)
+			if ok && !bytes.Equal(want, roots[storeKey]) {
+				mismatch = &Mismatch{Version: version, StoreKey: storeKey, Expected: want, Actual: roots[storeKey]}
+				return errStop
+			}
+		}
+		return nil
+	})
+	if err != nil && err != errStop {
+		return nil, err
+	}
+	return mismatch, nil
+}
+
+// errStop ends a Replay early without an error.
+var errStop = errors.New("stop replay")
@@ -0,0 +1,239 @@
+// Package iavl is an in-memory, immutable AVL+ tree which follows the balancing and hashing rules of
+// github.com/cosmos/iavl, so that replaying a store's writes reproduces the root hashes the chain committed.
+package iavl
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+// Tree is an immutable IAVL tree.  Set and Remove return a new tree which shares all unchanged nodes with the
+// receiver, so keeping a Tree keeps that version of the state.  The zero value, and a nil *Tree, is an empty tree.
+//
+// Every node created by a write gets the version of the write, as nodes cloned by iavl's mutable tree do.  Trees are
+// not safe for concurrent use because hashes are computed and cached lazily.
+type Tree struct {
+	root *node
+}
+
+// emptyHash is the root hash of an empty tree.
+var emptyHash = sha256.New().Sum(nil)
+
+// Hash returns the root hash of the tree.
+func (t *Tree) Hash() []byte {
+	if t == nil || t.root == nil {
+		return emptyHash
+	}
+	return t.root.computeHash()
+}
+
+// Size returns the number of keys in the tree.
+func (t *Tree) Size() int64 {
+	if t == nil || t.root == nil {
+		return 0
+	}
+	return t.root.size
+}
+
+// Height returns the height of the tree's root, 0 for a single leaf.
+func (t *Tree) Height() int8 {
+	if t == nil || t.root == nil {
+		return 0
+	}
+	return t.root.height
+}
+
+// Get returns the value of key, or nil if it is not set.  A key set to an empty value may also return nil, Has tells
+// the two apart.
+func (t *Tree) Get(key []byte) []byte {
+	if n := t.leaf(key); n != nil {
+		return n.value
+	}
+	return nil
+}
+
+// Has returns true if key is set, whatever its value.
+func (t *Tree) Has(key []byte) bool {
+	return t.leaf(key) != nil
+}
+
+// leaf returns the leaf of key, or nil if key is not set.
+func (t *Tree) leaf(key []byte) *node {
+	if t == nil {
+		return nil
+	}
+	n := t.root
+	for n != nil && !n.isLeaf() {
+		if bytes.Compare(key, n.key) < 0 {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	if n == nil || !bytes.Equal(n.key, key) {
+		return nil
+	}
+	return n
+}
+
+// Iterate calls fn with every key and value in ascending key order until fn returns false.
+func (t *Tree) Iterate(fn func(key, value []byte) bool) {
+	if t == nil || t.root == nil {
+		return
+	}
+	t.root.iterate(fn)
+}
+
+func (n *node) iterate(fn func(key, value []byte) bool) bool {
+	if n.isLeaf() {
+		return fn(n.key, n.value)
+	}
+	return n.left.iterate(fn) && n.right.iterate(fn)
+}
+
+// Set returns a tree with key set to value at version.  updated is true if key was already set.
+func (t *Tree) Set(key, value []byte, version int64) (tree *Tree, updated bool) {
+	if t == nil || t.root == nil {
+		return &Tree{root: newLeaf(key, value, version)}, false
+	}
+	root, updated := set(t.root, key, value, version)
+	return &Tree{root: root}, updated
+}
+
+func set(n *node, key, value []byte, version int64) (*node, bool) {
+	if n.isLeaf() {
+		switch bytes.Compare(key, n.key) {
+		case -1:
+			return &node{
+				key:     n.key,
+				height:  1,
+				size:    2,
+				left:    newLeaf(key, value, version),
+				right:   n,
+				version: version,
+			}, false
+		case 1:
+			return &node{
+				key:     key,
+				height:  1,
+				size:    2,
+				left:    n,
+				right:   newLeaf(key, value, version),
+				version: version,
+			}, false
+		default:
+			return newLeaf(key, value, version), true
+		}
+	}
+
+	c := n.clone(version)
+	var updated bool
+	if bytes.Compare(key, n.key) < 0 {
+		c.left, updated = set(n.left, key, value, version)
+	} else {
+		c.right, updated = set(n.right, key, value, version)
+	}
+	if updated {
+		// the shape of the tree is unchanged
+		return c, true
+	}
+	c.calcHeightAndSize()
+	return rebalance(c, version), false
+}
+
+// Remove returns a tree without key, written at version.  removed is false, and the receiver is returned, if key was
+// not set.
+func (t *Tree) Remove(key []byte, version int64) (tree *Tree, removed bool) {
+	if t == nil || t.root == nil {
+		return t, false
+	}
+	root, _, removed := remove(t.root, key, version)
+	if !removed {
+		return t, false
+	}
+	return &Tree{root: root}, true
+}
+
+// remove returns n without key.  If the smallest key of n changed it is returned as newKey, for the ancestor whose
+// key it is to be updated.
+func remove(n *node, key []byte, version int64) (newSelf *node, newKey []byte, removed bool) {
+	if n.isLeaf() {
+		if bytes.Equal(key, n.key) {
+			return nil, nil, true
+		}
+		return n, nil, false
+	}
+
+	if bytes.Compare(key, n.key) < 0 {
+		newLeft, newKey, removed := remove(n.left, key, version)
+		if !removed {
+			return n, nil, false
+		}
+		if newLeft == nil {
+			// the left leaf held the key, so the right subtree takes the place of n
+			return n.right, n.key, true
+		}
+		c := n.clone(version)
+		c.left = newLeft
+		c.calcHeightAndSize()
+		return rebalance(c, version), newKey, true
+	}
+
+	newRight, newKey, removed := remove(n.right, key, version)
+	if !removed {
+		return n, nil, false
+	}
+	if newRight == nil {
+		return n.left, nil, true
+	}
+	c := n.clone(version)
+	c.right = newRight
+	if newKey != nil {
+		c.key = newKey
+	}
+	c.calcHeightAndSize()
+	return rebalance(c, version), nil, true
+}
+
+// rebalance restores the AVL property of n, a node created at version whose children differ in height by at most 2.
+func rebalance(n *node, version int64) *node {
+	switch b := n.balance(); {
+	case b > 1:
+		if n.left.balance() >= 0 {
+			// left left
+			return rotateRight(n, version)
+		}
+		// left right
+		n.left = rotateLeft(n.left, version)
+		return rotateRight(n, version)
+	case b < -1:
+		if n.right.balance() <= 0 {
+			// right right
+			return rotateLeft(n, version)
+		}
+		// right left
+		n.right = rotateRight(n.right, version)
+		return rotateLeft(n, version)
+	}
+	return n
+}
+
+func rotateRight(n *node, version int64) *node {
+	n = n.clone(version)
+	l := n.left.clone(version)
+	n.left = l.right
+	l.right = n
+	n.calcHeightAndSize()
+	l.calcHeightAndSize()
+	return l
+}
+
+func rotateLeft(n *node, version int64) *node {
+	n = n.clone(version)
+	r := n.right.clone(version)
+	n.right = r.left
+	r.left = n
+	n.calcHeightAndSize()
+	r.calcHeightAndSize()
+	return r
+}
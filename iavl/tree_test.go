@@ -0,0 +1,208 @@
+package iavl_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/compact"
+	"github.com/kocubinski/costor-api/iavl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTree_SetRemove(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var tree *iavl.Tree
+	state := map[string][]byte{}
+	var hashes [][]byte
+	var trees []*iavl.Tree
+	for version := int64(1); version <= 200; version++ {
+		for i := 0; i < 20; i++ {
+			key := []byte(fmt.Sprintf("key-%03d", r.Intn(300)))
+			if r.Intn(4) == 0 {
+				var removed bool
+				tree, removed = tree.Remove(key, version)
+				_, ok := state[string(key)]
+				require.Equal(t, ok, removed)
+				delete(state, string(key))
+			} else {
+				value := []byte(fmt.Sprintf("value-%d", r.Int()))
+				var updated bool
+				tree, updated = tree.Set(key, value, version)
+				_, ok := state[string(key)]
+				require.Equal(t, ok, updated)
+				state[string(key)] = value
+			}
+		}
+		require.Equal(t, int64(len(state)), tree.Size())
+		for k, v := range state {
+			require.Equal(t, v, tree.Get([]byte(k)))
+		}
+		var keys []string
+		tree.Iterate(func(key, value []byte) bool {
+			keys = append(keys, string(key))
+			return true
+		})
+		require.True(t, sort.StringsAreSorted(keys))
+		require.Len(t, keys, len(state))
+
+		hashes = append(hashes, tree.Hash())
+		trees = append(trees, tree)
+	}
+	// earlier versions are untouched by later writes
+	for i, tree := range trees {
+		require.Equal(t, hashes[i], tree.Hash())
+	}
+	// balanced: a tree of n keys is at most ~1.44 log2(n) high
+	require.LessOrEqual(t, int(trees[len(trees)-1].Height()), 12)
+}
+
+func TestTree_Hash(t *testing.T) {
+	var tree *iavl.Tree
+	require.Len(t, tree.Hash(), 32)
+
+	tree, _ = tree.Set([]byte("key"), []byte("value"), 1)
+	require.Equal(t, api.LeafHash([]byte("key"), []byte("value"), 1), tree.Hash())
+
+	// the same key and value written at another version hash differently
+	other, _ := tree.Set([]byte("key"), []byte("value"), 2)
+	require.NotEqual(t, tree.Hash(), other.Hash())
+
+	removed, ok := other.Remove([]byte("key"), 3)
+	require.True(t, ok)
+	require.Equal(t, int64(0), removed.Size())
+	var empty *iavl.Tree
+	require.Equal(t, empty.Hash(), removed.Hash())
+}
+
+// TestTree_IAVLVectors checks root hashes against those computed by github.com/cosmos/iavl v0.19.4 for the same
+// writes.
+func TestTree_IAVLVectors(t *testing.T) {
+	expected := []string{
+		"9a226ac6880ffca9fc971ec61d6d41e5af6b882c1fcc4f4c8bed9be9b846044d",
+		"a4ec856844b390bd6203bf20e93032b00b3c51f2814c5c4ae07488417d739b91",
+		"581a71a95ce49e7f78343f6859cd15965f39733521532316694a06b8bc60970c",
+	}
+	var tree *iavl.Tree
+	for v := 1; v <= 3; v++ {
+		for i := 0; i < 10; i++ {
+			key := []byte(fmt.Sprintf("key%d", (i*7+v*3)%16))
+			if (i+v)%4 == 0 {
+				tree, _ = tree.Remove(key, int64(v))
+			} else {
+				tree, _ = tree.Set(key, []byte(fmt.Sprintf("value%d-%d", v, i)), int64(v))
+			}
+		}
+		require.Equal(t, expected[v-1], hex.EncodeToString(tree.Hash()), "version %d", v)
+	}
+}
+
+func TestReplayer(t *testing.T) {
+	dir := t.TempDir()
+	r := rand.New(rand.NewSource(2))
+	var changesets []*api.Changeset
+	for version := int64(1); version <= 100; version++ {
+		cs := &api.Changeset{Version: version}
+		for i := 0; i < 10; i++ {
+			storeKey := []string{"bank", "staking"}[r.Intn(2)]
+			cs.Nodes = append(cs.Nodes, &api.Node{
+				StoreKey: storeKey,
+				Key:      []byte{byte(r.Intn(50))},
+				Value:    []byte(fmt.Sprintf("%d", r.Int())),
+				Delete:   r.Intn(5) == 0,
+				Block:    version,
+			})
+		}
+		changesets = append(changesets, cs)
+	}
+	writeStores(t, dir, changesets, nil)
+
+	expected := iavl.NewReplayer()
+	roots := map[int64]map[string][]byte{}
+	for _, cs := range changesets {
+		require.NoError(t, expected.Apply(cs))
+		roots[cs.Version] = expected.RootHashes()
+	}
+
+	src, err := compact.NewMultiChangesetIterator(dir)
+	require.NoError(t, err)
+	replayer := iavl.NewReplayer()
+	versions := 0
+	err = replayer.Replay(src, func(version int64, hashes map[string][]byte) error {
+		require.Equal(t, roots[version], hashes)
+		versions++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 100, versions)
+
+	lookup := func(storeKey string, version int64) ([]byte, bool) {
+		hash, ok := roots[version][storeKey]
+		return hash, ok
+	}
+	src, err = compact.NewMultiChangesetIterator(dir)
+	require.NoError(t, err)
+	mismatch, err := iavl.NewReplayer().FindMismatch(src, lookup)
+	require.NoError(t, err)
+	require.Nil(t, mismatch)
+
+	// drop a set at version 40 which is not the only write to its store in that version
+	var dropped *api.Node
+	for _, node := range changesets[39].Nodes {
+		if !node.Delete {
+			dropped = node
+			break
+		}
+	}
+	dropDir := t.TempDir()
+	writeStores(t, dropDir, changesets, dropped)
+	src, err = compact.NewMultiChangesetIterator(dropDir)
+	require.NoError(t, err)
+	mismatch, err = iavl.NewReplayer().FindMismatch(src, lookup)
+	require.NoError(t, err)
+	require.NotNil(t, mismatch)
+	require.Equal(t, int64(40), mismatch.Version)
+	require.Equal(t, dropped.StoreKey, mismatch.StoreKey)
+	require.False(t, bytes.Equal(mismatch.Expected, mismatch.Actual))
+}
+
+// writeStores compacts changesets into one directory per store under dir, leaving out skip.
+func writeStores(t *testing.T, dir string, changesets []*api.Changeset, skip *api.Node) {
+	stores := map[string]*compact.StreamingContext{}
+	done := make(chan error)
+	for _, storeKey := range []string{"bank", "staking"} {
+		storeDir := filepath.Join(dir, storeKey)
+		require.NoError(t, os.Mkdir(storeDir, 0755))
+		ctx := &compact.StreamingContext{
+			OutDir:       storeDir,
+			MaxFileSize:  1024,
+			OrderedInput: true,
+			In:           make(chan compact.Sequenced),
+		}
+		stores[storeKey] = ctx
+		go func() {
+			_, err := ctx.Compact()
+			done <- err
+		}()
+	}
+	for _, cs := range changesets {
+		for _, node := range cs.Nodes {
+			if node == skip {
+				continue
+			}
+			stores[node.StoreKey].In <- &api.Node{Key: node.Key, Value: node.Value, Delete: node.Delete, Block: node.Block}
+		}
+	}
+	for _, ctx := range stores {
+		close(ctx.In)
+	}
+	for range stores {
+		require.NoError(t, <-done)
+	}
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The DenomTrace behavior below follows ibc-go's apps/transfer/types/trace.go and the identifier validators of its
+// core/24-host package, so that denoms resolve to the same ibc/ hashes the chain stores balances under.
+
+// DenomPrefix is the prefix of the denom of every token received over IBC.
+const DenomPrefix = "ibc"
+
+const (
+	channelPrefix              = "channel-"
+	maxIdentifierLength        = 64
+	maxPortIdentifierLength    = 128
+	minChannelIdentifierLength = 8
+	minPortIdentifierLength    = 2
+)
+
+// ErrInvalidIdentifier is returned by ValidatePortID and ValidateChannelID.
+var ErrInvalidIdentifier = errors.New("invalid identifier")
+
+var (
+	isValidID         = regexp.MustCompile(`^[a-zA-Z0-9\.\_\+\-\#\[\]\<\>]+$`).MatchString
+	isChannelIDFormat = regexp.MustCompile(`^channel-[0-9]{1,20}$`).MatchString
+)
+
+// ParseDenomTrace parses a full denom path, such as transfer/channel-0/uatom, into a DenomTrace.  Port and channel
+// pairs are taken into the path as long as the channel identifier is in ibc-go's channel-N format; everything from
+// the first pair which is not becomes the base denom.  A denom without a '/' is a native denom with an empty path.
+func ParseDenomTrace(rawDenom string) *DenomTrace {
+	denomSplit := strings.Split(rawDenom, "/")
+	if denomSplit[0] == rawDenom {
+		return &DenomTrace{BaseDenom: rawDenom}
+	}
+	var path, baseDenom []string
+	length := len(denomSplit)
+	for i := 0; i < length; i += 2 {
+		if i < length-1 && length > 2 && isValidChannelID(denomSplit[i+1]) {
+			path = append(path, denomSplit[i], denomSplit[i+1])
+		} else {
+			baseDenom = denomSplit[i:]
+			break
+		}
+	}
+	return &DenomTrace{Path: strings.Join(path, "/"), BaseDenom: strings.Join(baseDenom, "/")}
+}
+
+// GetFullDenomPath returns the path and base denom joined by a '/', or just the base denom if the path is empty.
+func (dt *DenomTrace) GetFullDenomPath() string {
+	if dt.Path == "" {
+		return dt.BaseDenom
+	}
+	return dt.Path + "/" + dt.BaseDenom
+}
+
+// Hash returns the sha256 of the full denom path.
+func (dt *DenomTrace) Hash() []byte {
+	hash := sha256.Sum256([]byte(dt.GetFullDenomPath()))
+	return hash[:]
+}
+
+// IBCDenom returns the denom the token is held under: ibc/ followed by the upper case hex of Hash, or the base denom
+// of a native token.
+func (dt *DenomTrace) IBCDenom() string {
+	if dt.Path == "" {
+		return dt.BaseDenom
+	}
+	return fmt.Sprintf("%s/%s", DenomPrefix, strings.ToUpper(hex.EncodeToString(dt.Hash())))
+}
+
+// Validate checks that the base denom is not blank and that the path, if any, is made of valid port and channel
+// identifier pairs.  The base denom itself is not validated.
+func (dt *DenomTrace) Validate() error {
+	switch {
+	case dt.Path == "" && dt.BaseDenom != "":
+		return nil
+	case strings.TrimSpace(dt.BaseDenom) == "":
+		return errors.New("base denomination cannot be blank")
+	}
+	identifiers := strings.Split(dt.Path, "/")
+	if len(identifiers)%2 != 0 {
+		return fmt.Errorf("trace info must come in pairs of port and channel identifiers '{portID}/{channelID}', "+
+			"got the identifiers: %s", identifiers)
+	}
+	for i := 0; i < len(identifiers); i += 2 {
+		if err := ValidatePortID(identifiers[i]); err != nil {
+			return fmt.Errorf("invalid port ID at position %d: %w", i, err)
+		}
+		if err := ValidateChannelID(identifiers[i+1]); err != nil {
+			return fmt.Errorf("invalid channel ID at position %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// ValidatePortID checks a port identifier as ibc-go's host.PortIdentifierValidator does.
+func ValidatePortID(id string) error {
+	return validateIdentifier(id, minPortIdentifierLength, maxPortIdentifierLength)
+}
+
+// ValidateChannelID checks a channel identifier as ibc-go's host.ChannelIdentifierValidator does.
+func ValidateChannelID(id string) error {
+	return validateIdentifier(id, minChannelIdentifierLength, maxIdentifierLength)
+}
+
+func validateIdentifier(id string, min, max int) error {
+	switch {
+	case strings.TrimSpace(id) == "":
+		return fmt.Errorf("%w: identifier cannot be blank", ErrInvalidIdentifier)
+	case strings.Contains(id, "/"):
+		return fmt.Errorf("%w: identifier %s cannot contain separator '/'", ErrInvalidIdentifier, id)
+	case len(id) < min || len(id) > max:
+		return fmt.Errorf("%w: identifier %s has invalid length: %d, must be between %d-%d characters",
+			ErrInvalidIdentifier, id, len(id), min, max)
+	case !isValidID(id):
+		return fmt.Errorf("%w: identifier %s must contain only alphanumeric or the following characters: "+
+			"'.', '_', '+', '-', '#', '[', ']', '<', '>'", ErrInvalidIdentifier, id)
+	}
+	return nil
+}
+
+// isValidChannelID returns true if id is in the channel-N format ibc-go generates channel identifiers in.
+func isValidChannelID(id string) bool {
+	if !isChannelIDFormat(id) {
+		return false
+	}
+	_, err := strconv.ParseUint(strings.TrimPrefix(id, channelPrefix), 10, 64)
+	return err == nil
+}
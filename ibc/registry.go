@@ -0,0 +1,206 @@
+// Package ibc resolves IBC denoms from the state of the transfer store.
+package ibc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	api "github.com/kocubinski/costor-api"
+	"google.golang.org/protobuf/proto"
+)
+
+// StoreKey is the store key of the ibc-go transfer module.
+const StoreKey = "transfer"
+
+// DenomTraceKeyPrefix prefixes the keys under which the transfer store holds a DenomTrace, by its hash.
+var DenomTraceKeyPrefix = []byte{0x02}
+
+// Registry maps denom trace hashes to denom traces as of any height, from the writes to the transfer store.
+type Registry struct {
+	// entries holds the writes to each hash, by upper case hex hash, in ascending height order
+	entries map[string][]entry
+	// height is the highest block applied
+	height int64
+}
+
+type entry struct {
+	height int64
+	// trace is nil if the trace was deleted at height
+	trace *api.DenomTrace
+}
+
+func NewRegistry() *Registry {
+	return &Registry{entries: make(map[string][]entry)}
+}
+
+// Height returns the highest block applied to the registry.
+func (r *Registry) Height() int64 {
+	return r.height
+}
+
+// Len returns the number of hashes the registry holds.
+func (r *Registry) Len() int {
+	return len(r.entries)
+}
+
+// Apply records a write to the transfer store.  Nodes of other stores, and of other keys of the transfer store, are
+// ignored; a node without a store key is taken to be from the transfer store.  Nodes must be applied in block order.
+func (r *Registry) Apply(node *api.Node) error {
+	if node.Block < r.height {
+		return fmt.Errorf("block %d applied after block %d", node.Block, r.height)
+	}
+	r.height = node.Block
+	if node.StoreKey != "" && node.StoreKey != StoreKey {
+		return nil
+	}
+	if !bytes.HasPrefix(node.Key, DenomTraceKeyPrefix) {
+		return nil
+	}
+	hash := node.Key[len(DenomTraceKeyPrefix):]
+	e := entry{height: node.Block}
+	if !node.Delete {
+		e.trace = &api.DenomTrace{}
+		if err := proto.Unmarshal(node.Value, e.trace); err != nil {
+			return fmt.Errorf("denom trace %X at block %d: %w", hash, node.Block, err)
+		}
+		if !bytes.Equal(e.trace.Hash(), hash) {
+			return fmt.Errorf("denom trace %s at block %d is stored under hash %X", e.trace.GetFullDenomPath(),
+				node.Block, hash)
+		}
+	}
+	key := hashKey(hash)
+	entries := r.entries[key]
+	if n := len(entries); n > 0 && entries[n-1].height == node.Block {
+		// the last write of a block wins
+		entries[n-1] = e
+	} else {
+		r.entries[key] = append(entries, e)
+	}
+	return nil
+}
+
+// ApplyAll applies every node of itr.
+func (r *Registry) ApplyAll(itr api.NodeIterator) error {
+	var err error
+	for ; itr.Valid(); err = itr.Next() {
+		if err != nil {
+			return err
+		}
+		if err := r.Apply(itr.GetNode()); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// Lookup returns the denom trace of denom as of height, the state after block height was committed.  denom is either
+// an IBC denom, ibc/<hash>, or the bare hash.
+func (r *Registry) Lookup(denom string, height int64) (*api.DenomTrace, bool) {
+	hash := strings.TrimPrefix(denom, api.DenomPrefix+"/")
+	entries := r.entries[strings.ToUpper(hash)]
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].height > height })
+	if i == 0 || entries[i-1].trace == nil {
+		return nil, false
+	}
+	return entries[i-1].trace, true
+}
+
+// Resolve returns the full denom path of an IBC denom as of height, such as transfer/channel-0/uatom for
+// ibc/27394FB092D2ECCD56123C74F36E4C1F926001CEADA9CA97EA622B25F41E5EB2.  Native denoms, and IBC denoms which are not
+// known at height, are returned as they are.
+func (r *Registry) Resolve(denom string, height int64) string {
+	if !strings.HasPrefix(denom, api.DenomPrefix+"/") {
+		return denom
+	}
+	if trace, ok := r.Lookup(denom, height); ok {
+		return trace.GetFullDenomPath()
+	}
+	return denom
+}
+
+func hashKey(hash []byte) string {
+	return strings.ToUpper(hex.EncodeToString(hash))
+}
+
+// registryJSON is the saved form of a Registry.
+type registryJSON struct {
+	Height int64       `json:"height"`
+	Traces []traceJSON `json:"traces"`
+}
+
+type traceJSON struct {
+	Hash      string `json:"hash"`
+	Height    int64  `json:"height"`
+	Path      string `json:"path,omitempty"`
+	BaseDenom string `json:"base_denom,omitempty"`
+	Deleted   bool   `json:"deleted,omitempty"`
+}
+
+func (r *Registry) MarshalJSON() ([]byte, error) {
+	out := registryJSON{Height: r.height, Traces: []traceJSON{}}
+	hashes := make([]string, 0, len(r.entries))
+	for hash := range r.entries {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		for _, e := range r.entries[hash] {
+			t := traceJSON{Hash: hash, Height: e.height, Deleted: e.trace == nil}
+			if e.trace != nil {
+				t.Path, t.BaseDenom = e.trace.Path, e.trace.BaseDenom
+			}
+			out.Traces = append(out.Traces, t)
+		}
+	}
+	return json.Marshal(out)
+}
+
+func (r *Registry) UnmarshalJSON(bz []byte) error {
+	var in registryJSON
+	if err := json.Unmarshal(bz, &in); err != nil {
+		return err
+	}
+	r.entries = make(map[string][]entry)
+	r.height = in.Height
+	for _, t := range in.Traces {
+		e := entry{height: t.Height}
+		if !t.Deleted {
+			e.trace = &api.DenomTrace{Path: t.Path, BaseDenom: t.BaseDenom}
+			if got := hashKey(e.trace.Hash()); got != t.Hash {
+				return fmt.Errorf("denom trace %s is saved under hash %s, not %s", e.trace.GetFullDenomPath(), t.Hash, got)
+			}
+		}
+		r.entries[t.Hash] = append(r.entries[t.Hash], e)
+	}
+	for _, entries := range r.entries {
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].height < entries[j].height })
+	}
+	return nil
+}
+
+// Save writes the registry to path as JSON.
+func (r *Registry) Save(path string) error {
+	bz, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, bz, 0644)
+}
+
+// LoadRegistry reads a registry written by Save.  Nodes from blocks after its Height can be applied to it.
+func LoadRegistry(path string) (*Registry, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := NewRegistry()
+	if err := json.Unmarshal(bz, r); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
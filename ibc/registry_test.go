@@ -0,0 +1,72 @@
+package ibc_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/ibc"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func traceNode(t *testing.T, fullPath string, block int64) *api.Node {
+	trace := api.ParseDenomTrace(fullPath)
+	bz, err := proto.Marshal(trace)
+	require.NoError(t, err)
+	return &api.Node{
+		StoreKey: ibc.StoreKey,
+		Key:      append([]byte{0x02}, trace.Hash()...),
+		Value:    bz,
+		Block:    block,
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	const atom = "ibc/27394FB092D2ECCD56123C74F36E4C1F926001CEADA9CA97EA622B25F41E5EB2"
+	nodes := &api.Changeset{Nodes: []*api.Node{
+		{StoreKey: "bank", Key: []byte{0x02, 0x01}, Value: []byte("not a trace"), Block: 5},
+		traceNode(t, "transfer/channel-141/uosmo", 5),
+		// port binding and other keys of the transfer store
+		{StoreKey: ibc.StoreKey, Key: []byte{0x01}, Value: []byte("port"), Block: 8},
+		traceNode(t, "transfer/channel-0/uatom", 10),
+	}}
+	r := ibc.NewRegistry()
+	require.NoError(t, r.ApplyAll(nodes.Iterator()))
+	require.Equal(t, 2, r.Len())
+	require.Equal(t, int64(10), r.Height())
+
+	_, ok := r.Lookup(atom, 9)
+	require.False(t, ok)
+	require.Equal(t, atom, r.Resolve(atom, 9))
+	trace, ok := r.Lookup(atom, 10)
+	require.True(t, ok)
+	require.Equal(t, "transfer/channel-0", trace.Path)
+	require.Equal(t, "uatom", trace.BaseDenom)
+	require.Equal(t, "transfer/channel-0/uatom", r.Resolve(atom, 11))
+	require.Equal(t, "transfer/channel-0/uatom", r.Resolve("ibc/27394fb092d2eccd56123c74f36e4c1f926001ceada9ca97ea622b25f41e5eb2", 11))
+	require.Equal(t, "uatom", r.Resolve("uatom", 11))
+
+	path := filepath.Join(t.TempDir(), "registry.json")
+	require.NoError(t, r.Save(path))
+	loaded, err := ibc.LoadRegistry(path)
+	require.NoError(t, err)
+	require.Equal(t, r.Height(), loaded.Height())
+	require.Equal(t, "transfer/channel-0/uatom", loaded.Resolve(atom, 10))
+	require.Equal(t, atom, loaded.Resolve(atom, 9))
+	require.Equal(t, "transfer/channel-141/uosmo",
+		loaded.Resolve("ibc/14F9BC3E44B8A9C1BE1FB08980FAB87034C9905EF17CF2F5008FC085218811CC", 5))
+
+	// writes continue from the saved height
+	del := traceNode(t, "transfer/channel-0/uatom", 12)
+	del.Delete, del.Value = true, nil
+	require.NoError(t, loaded.Apply(del))
+	require.Equal(t, atom, loaded.Resolve(atom, 12))
+	require.Equal(t, "transfer/channel-0/uatom", loaded.Resolve(atom, 11))
+	require.Error(t, loaded.Apply(traceNode(t, "transfer/channel-1/uatom", 11)))
+
+	// a trace stored under the wrong hash
+	bad := traceNode(t, "transfer/channel-1/uatom", 13)
+	bad.Key[1] ^= 0xff
+	require.Error(t, loaded.Apply(bad))
+}
@@ -0,0 +1,200 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/compact"
+	"github.com/kocubinski/costor-api/logz"
+)
+
+var log = logz.Logger.With().Str("module", "ingest").Logger()
+
+// ErrIncompleteBlock is returned for a block data file which is shorter than its length header says, as it is while
+// the node is still writing it.
+var ErrIncompleteBlock = errors.New("incomplete block data file")
+
+// FileStreamDir is a directory written by the Cosmos SDK's ADR-038 file streaming service.  For every block N the
+// service writes block-N-meta and block-N-data, each prefixed with the configured file prefix and a dash if there is
+// one.  A data file is an 8 byte big endian length followed by that many bytes of uvarint length prefixed StoreKVPair
+// messages.
+type FileStreamDir struct {
+	Dir string
+	// Prefix is the file prefix the streaming service was configured with, if any.
+	Prefix string
+	// StartHeight skips blocks below it.
+	StartHeight int64
+	// Follow, if set, makes Run wait for blocks still being written and for new blocks, polling every Poll (default
+	// one second), until it is done.
+	Follow context.Context
+	Poll   time.Duration
+}
+
+func (d *FileStreamDir) dataFile(height int64) string {
+	name := fmt.Sprintf("block-%d-data", height)
+	if d.Prefix != "" {
+		name = d.Prefix + "-" + name
+	}
+	return filepath.Join(d.Dir, name)
+}
+
+// Heights returns the heights of the blocks in the directory with a data file, at or above StartHeight, in ascending
+// order.
+func (d *FileStreamDir) Heights() ([]int64, error) {
+	entries, err := os.ReadDir(d.Dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix := "block-"
+	if d.Prefix != "" {
+		prefix = d.Prefix + "-" + prefix
+	}
+	var heights []int64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, "-data") {
+			continue
+		}
+		height, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, prefix), "-data"), 10, 64)
+		if err != nil || height < d.StartHeight {
+			continue
+		}
+		heights = append(heights, height)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights, nil
+}
+
+// ReadBlockData reads the pairs of a block data file.  It returns ErrIncompleteBlock if the file is shorter than its
+// length header.
+func ReadBlockData(path string) ([]*api.StoreKVPair, error) {
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(bz) < 8 {
+		return nil, fmt.Errorf("%s: %w", path, ErrIncompleteBlock)
+	}
+	length := binary.BigEndian.Uint64(bz[:8])
+	switch payload := uint64(len(bz) - 8); {
+	case payload < length:
+		return nil, fmt.Errorf("%s: %w: %d of %d bytes", path, ErrIncompleteBlock, payload, length)
+	case payload > length:
+		return nil, fmt.Errorf("%s: %d bytes after the %d bytes in the length header", path, payload-length, length)
+	}
+
+	var pairs []*api.StoreKVPair
+	reader := NewReader(bytes.NewReader(bz[8:]), Uvarint)
+	for {
+		pair := &api.StoreKVPair{}
+		if err := reader.ReadMessage(pair); err == io.EOF {
+			return pairs, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: pair %d: %w", path, len(pairs), err)
+		}
+		pairs = append(pairs, pair)
+	}
+}
+
+// Run sends every pair in the directory to in as a Node, block by block in height order, and returns the number of
+// nodes sent.  Without Follow, Run returns once every block has been read; a last block which is still being written
+// is left for a later run.  With Follow, Run returns when Follow is done.
+func (d *FileStreamDir) Run(in chan<- compact.Sequenced) (int, error) {
+	return d.run(in, nil)
+}
+
+// errStopped is returned by run when stop is closed.
+var errStopped = errors.New("stopped")
+
+// run is Run, which also returns errStopped once stop is closed.
+func (d *FileStreamDir) run(in chan<- compact.Sequenced, stop <-chan struct{}) (int, error) {
+	count := 0
+	next := d.StartHeight
+	poll := d.Poll
+	if poll <= 0 {
+		poll = time.Second
+	}
+	for {
+		heights, err := d.Heights()
+		if err != nil {
+			return count, err
+		}
+		for i, height := range heights {
+			if height < next {
+				continue
+			}
+			pairs, err := ReadBlockData(d.dataFile(height))
+			if errors.Is(err, ErrIncompleteBlock) && i == len(heights)-1 {
+				if d.Follow == nil {
+					log.Warn().Msgf("stopping before block %d which is still being written", height)
+					return count, nil
+				}
+				// wait for the node to finish writing it
+				break
+			}
+			if err != nil {
+				return count, err
+			}
+			for _, pair := range pairs {
+				select {
+				case in <- PairNode(pair, height):
+				case <-stop:
+					return count, errStopped
+				}
+			}
+			count += len(pairs)
+			next = height + 1
+		}
+		if d.Follow == nil {
+			return count, nil
+		}
+		select {
+		case <-d.Follow.Done():
+			return count, nil
+		case <-stop:
+			return count, errStopped
+		case <-time.After(poll):
+		}
+	}
+}
+
+// Compact converts the directory into segments written by out, in one call.  out.In is created and closed by
+// Compact, and out.OrderedInput is set since blocks are read in height order.  If out.Compact fails, reading stops and
+// its error is returned.
+func (d *FileStreamDir) Compact(out *compact.StreamingContext) (*compact.Stats, error) {
+	out.In = make(chan compact.Sequenced)
+	out.OrderedInput = true
+	type result struct {
+		stats *compact.Stats
+		err   error
+	}
+	done := make(chan result, 1)
+	stop := make(chan struct{})
+	go func() {
+		stats, err := out.Compact()
+		done <- result{stats, err}
+		// Compact only returns before In is closed on error
+		close(stop)
+	}()
+	_, err := d.run(out.In, stop)
+	close(out.In)
+	res := <-done
+	if err != nil && !errors.Is(err, errStopped) {
+		return nil, err
+	}
+	if res.err != nil {
+		return nil, res.err
+	}
+	return res.stats, nil
+}
@@ -0,0 +1,127 @@
+package ingest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/compact"
+	"github.com/kocubinski/costor-api/ingest"
+	"github.com/stretchr/testify/require"
+)
+
+// writeBlock writes a block data file as the SDK's file streaming service does, cut to keep bytes if keep >= 0.
+func writeBlock(t *testing.T, dir string, height int64, pairs int, keep int) {
+	var payload bytes.Buffer
+	for i := 0; i < pairs; i++ {
+		writeMessages(t, &payload, ingest.Uvarint, &api.StoreKVPair{
+			StoreKey: "bank",
+			Key:      []byte(fmt.Sprintf("key-%d-%d", height, i)),
+			Value:    []byte("value"),
+		})
+	}
+	bz := binary.BigEndian.AppendUint64(nil, uint64(payload.Len()))
+	bz = append(bz, payload.Bytes()...)
+	if keep >= 0 {
+		bz = bz[:keep]
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("block-%d-data", height)), bz, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("block-%d-meta", height)), nil, 0644))
+}
+
+func TestFileStreamDir(t *testing.T) {
+	dir := t.TempDir()
+	for height := int64(1); height <= 12; height++ {
+		writeBlock(t, dir, height, 10, -1)
+	}
+	// still being written
+	writeBlock(t, dir, 13, 10, 20)
+
+	src := &ingest.FileStreamDir{Dir: dir, StartHeight: 2}
+	heights, err := src.Heights()
+	require.NoError(t, err)
+	require.Len(t, heights, 12)
+	require.Equal(t, int64(2), heights[0])
+	require.Equal(t, int64(13), heights[11])
+
+	outDir := t.TempDir()
+	stats, err := src.Compact(&compact.StreamingContext{OutDir: outDir, MaxFileSize: 1024})
+	require.NoError(t, err)
+	require.Equal(t, 110, stats.NodeCount)
+
+	itr, err := compact.NewSequencedIterator(outDir, func() *api.Node { return &api.Node{} })
+	require.NoError(t, err)
+	var (
+		count int
+		block int64 = 2
+	)
+	for ; itr.Valid(); err = itr.Next() {
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, itr.Node.Block, block)
+		block = itr.Node.Block
+		require.Equal(t, fmt.Sprintf("key-%d-%d", block, count%10), string(itr.Node.Key))
+		count++
+	}
+	require.NoError(t, err)
+	require.Equal(t, 110, count)
+	require.Equal(t, int64(12), block)
+}
+
+func TestFileStreamDir_CompactFails(t *testing.T) {
+	dir := t.TempDir()
+	for height := int64(1); height <= 100; height++ {
+		writeBlock(t, dir, height, 1_000, -1)
+	}
+	// the first segment is written long before the last block is read, and fails
+	src := &ingest.FileStreamDir{Dir: dir}
+	_, err := src.Compact(&compact.StreamingContext{OutDir: filepath.Join(t.TempDir(), "missing"), MaxFileSize: 1024})
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFileStreamDir_Follow(t *testing.T) {
+	dir := t.TempDir()
+	writeBlock(t, dir, 1, 5, -1)
+	writeBlock(t, dir, 2, 5, 30)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := &ingest.FileStreamDir{Dir: dir, Follow: ctx, Poll: 10 * time.Millisecond}
+	in := make(chan compact.Sequenced)
+	var (
+		mu     sync.Mutex
+		blocks []int64
+	)
+	go func() {
+		for s := range in {
+			mu.Lock()
+			blocks = append(blocks, s.Sequence())
+			mu.Unlock()
+		}
+	}()
+	received := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(blocks)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := src.Run(in)
+		close(in)
+		done <- err
+	}()
+
+	require.Eventually(t, func() bool { return received() == 5 }, time.Second, 5*time.Millisecond)
+	writeBlock(t, dir, 2, 5, -1)
+	writeBlock(t, dir, 3, 5, -1)
+	require.Eventually(t, func() bool { return received() == 15 }, time.Second, 5*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+	require.Equal(t, int64(3), blocks[14])
+}
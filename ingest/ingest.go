@@ -0,0 +1,277 @@
+// Package ingest converts state streamed by Cosmos SDK nodes, as StoreKVPair or the legacy Osmosis StoreKVPairs
+// messages, into api.Node records for compaction.
+package ingest
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/compact"
+	"google.golang.org/protobuf/proto"
+)
+
+// LengthPrefix is the encoding of the length which precedes each message in a stream.
+type LengthPrefix int
+
+const (
+	// Uvarint prefixes, as written by the SDK's codec.MarshalLengthPrefixed and the ADR-038 file streamer.
+	Uvarint LengthPrefix = iota
+	// Uint32LE prefixes, as written in costor segments.
+	Uint32LE
+	// Uint64BE prefixes.
+	Uint64BE
+)
+
+func (p LengthPrefix) String() string {
+	switch p {
+	case Uvarint:
+		return "uvarint"
+	case Uint32LE:
+		return "uint32-le"
+	case Uint64BE:
+		return "uint64-be"
+	}
+	return fmt.Sprintf("LengthPrefix(%d)", int(p))
+}
+
+// ParseLengthPrefix returns the LengthPrefix named s, as printed by LengthPrefix.String.
+func ParseLengthPrefix(s string) (LengthPrefix, error) {
+	for _, p := range []LengthPrefix{Uvarint, Uint32LE, Uint64BE} {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown length prefix %q", s)
+}
+
+// MaxMessageSize bounds the length a Reader accepts, so that a corrupt prefix fails instead of allocating.
+const MaxMessageSize = 256 * 1024 * 1024
+
+// ErrMessageTooLarge is returned for a length prefix above MaxMessageSize.
+var ErrMessageTooLarge = errors.New("message too large")
+
+// Reader reads length prefixed proto messages from a stream.
+type Reader struct {
+	r      *bufio.Reader
+	prefix LengthPrefix
+	buf    []byte
+}
+
+func NewReader(r io.Reader, prefix LengthPrefix) *Reader {
+	return &Reader{r: bufio.NewReaderSize(r, 64*1024), prefix: prefix}
+}
+
+// ReadMessage reads the next message into msg.  It returns io.EOF at the end of the stream, and io.ErrUnexpectedEOF
+// if the stream ends within a message.
+func (r *Reader) ReadMessage(msg proto.Message) error {
+	length, err := r.readLength()
+	if err != nil {
+		return err
+	}
+	if length > MaxMessageSize {
+		return fmt.Errorf("%w: %d bytes", ErrMessageTooLarge, length)
+	}
+	if uint64(cap(r.buf)) < length {
+		r.buf = make([]byte, length)
+	}
+	bz := r.buf[:length]
+	if _, err := io.ReadFull(r.r, bz); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return proto.Unmarshal(bz, msg)
+}
+
+func (r *Reader) readLength() (uint64, error) {
+	switch r.prefix {
+	case Uvarint:
+		length, err := binary.ReadUvarint(r.r)
+		if err == io.EOF {
+			return 0, io.EOF
+		}
+		return length, err
+	case Uint32LE, Uint64BE:
+		var bz [8]byte
+		n := 4
+		if r.prefix == Uint64BE {
+			n = 8
+		}
+		if _, err := io.ReadFull(r.r, bz[:n]); err != nil {
+			return 0, err
+		}
+		if r.prefix == Uint32LE {
+			return uint64(binary.LittleEndian.Uint32(bz[:4])), nil
+		}
+		return binary.BigEndian.Uint64(bz[:]), nil
+	}
+	return 0, fmt.Errorf("unknown length prefix %s", r.prefix)
+}
+
+// Writer writes length prefixed proto messages to a stream.
+type Writer struct {
+	w      io.Writer
+	prefix LengthPrefix
+	buf    []byte
+}
+
+func NewWriter(w io.Writer, prefix LengthPrefix) *Writer {
+	return &Writer{w: w, prefix: prefix}
+}
+
+// WriteMessage writes msg and its length prefix in a single write.
+func (w *Writer) WriteMessage(msg proto.Message) error {
+	size := proto.Size(msg)
+	bz := w.buf[:0]
+	switch w.prefix {
+	case Uvarint:
+		bz = binary.AppendUvarint(bz, uint64(size))
+	case Uint32LE:
+		bz = binary.LittleEndian.AppendUint32(bz, uint32(size))
+	case Uint64BE:
+		bz = binary.BigEndian.AppendUint64(bz, uint64(size))
+	default:
+		return fmt.Errorf("unknown length prefix %s", w.prefix)
+	}
+	bz, err := proto.MarshalOptions{}.MarshalAppend(bz, msg)
+	if err != nil {
+		return err
+	}
+	w.buf = bz
+	_, err = w.w.Write(bz)
+	return err
+}
+
+// PairNode converts a StoreKVPair written at block into a Node.
+func PairNode(pair *api.StoreKVPair, block int64) *api.Node {
+	node := &api.Node{
+		StoreKey: pair.StoreKey,
+		Key:      pair.Key,
+		Delete:   pair.Delete,
+		Block:    block,
+	}
+	if !pair.Delete {
+		node.Value = pair.Value
+	}
+	return node
+}
+
+// BatchNodes converts a StoreKVPairs batch into Nodes at its block height.  Each key is prefixed with the batch's
+// key prefix, and a pair without a store key takes the batch's.
+func BatchNodes(batch *api.StoreKVPairs) []*api.Node {
+	nodes := make([]*api.Node, len(batch.Pairs))
+	for i, pair := range batch.Pairs {
+		node := PairNode(pair, batch.BlockHeight)
+		if node.StoreKey == "" {
+			node.StoreKey = batch.StoreKey
+		}
+		if len(batch.KeyPrefix) > 0 {
+			key := make([]byte, 0, len(batch.KeyPrefix)+len(pair.Key))
+			node.Key = append(append(key, batch.KeyPrefix...), pair.Key...)
+		}
+		nodes[i] = node
+	}
+	return nodes
+}
+
+// ReadPairs reads a stream of StoreKVPair messages, which carry no height, and sends each to in as a Node at block.
+// It returns the number of nodes sent.
+func ReadPairs(r io.Reader, prefix LengthPrefix, block int64, in chan<- compact.Sequenced) (int, error) {
+	reader := NewReader(r, prefix)
+	count := 0
+	for {
+		pair := &api.StoreKVPair{}
+		if err := reader.ReadMessage(pair); err == io.EOF {
+			return count, nil
+		} else if err != nil {
+			return count, fmt.Errorf("pair %d: %w", count, err)
+		}
+		in <- PairNode(pair, block)
+		count++
+	}
+}
+
+// ReadBatches reads a stream of StoreKVPairs messages and sends every pair to in as a Node.  It returns the number of
+// nodes sent.
+func ReadBatches(r io.Reader, prefix LengthPrefix, in chan<- compact.Sequenced) (int, error) {
+	reader := NewReader(r, prefix)
+	count := 0
+	for batches := 0; ; batches++ {
+		batch := &api.StoreKVPairs{}
+		if err := reader.ReadMessage(batch); err == io.EOF {
+			return count, nil
+		} else if err != nil {
+			return count, fmt.Errorf("batch %d: %w", batches, err)
+		}
+		for _, node := range BatchNodes(batch) {
+			in <- node
+		}
+		count += len(batch.Pairs)
+	}
+}
+
+// ReadPairsFile is ReadPairs over the file at path, which is decompressed if its name ends in .gz.
+func ReadPairsFile(path string, prefix LengthPrefix, block int64, in chan<- compact.Sequenced) (int, error) {
+	f, err := Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n, err := ReadPairs(f, prefix, block, in)
+	if err != nil {
+		return n, fmt.Errorf("%s: %w", path, err)
+	}
+	return n, nil
+}
+
+// ReadBatchesFile is ReadBatches over the file at path, which is decompressed if its name ends in .gz.
+func ReadBatchesFile(path string, prefix LengthPrefix, in chan<- compact.Sequenced) (int, error) {
+	f, err := Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n, err := ReadBatches(f, prefix, in)
+	if err != nil {
+		return n, fmt.Errorf("%s: %w", path, err)
+	}
+	return n, nil
+}
+
+// Open opens the file at path for reading, through a gzip reader if its name ends in .gz.
+func Open(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return f, nil
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &gzipFile{Reader: zr, f: f}, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (g *gzipFile) Close() error {
+	err := g.Reader.Close()
+	if ferr := g.f.Close(); err == nil {
+		err = ferr
+	}
+	return err
+}
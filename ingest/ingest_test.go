@@ -0,0 +1,149 @@
+package ingest_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/compact"
+	"github.com/kocubinski/costor-api/ingest"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func writeMessages(t *testing.T, w io.Writer, prefix ingest.LengthPrefix, msgs ...proto.Message) {
+	for _, msg := range msgs {
+		bz, err := proto.Marshal(msg)
+		require.NoError(t, err)
+		var lengthBz []byte
+		switch prefix {
+		case ingest.Uvarint:
+			lengthBz = binary.AppendUvarint(nil, uint64(len(bz)))
+		case ingest.Uint32LE:
+			lengthBz = binary.LittleEndian.AppendUint32(nil, uint32(len(bz)))
+		case ingest.Uint64BE:
+			lengthBz = binary.BigEndian.AppendUint64(nil, uint64(len(bz)))
+		}
+		_, err = w.Write(append(lengthBz, bz...))
+		require.NoError(t, err)
+	}
+}
+
+func collect(in chan compact.Sequenced) func() []*api.Node {
+	var nodes []*api.Node
+	done := make(chan struct{})
+	go func() {
+		for s := range in {
+			nodes = append(nodes, s.(*api.Node))
+		}
+		close(done)
+	}()
+	return func() []*api.Node {
+		close(in)
+		<-done
+		return nodes
+	}
+}
+
+func TestReadBatches(t *testing.T) {
+	batches := []proto.Message{
+		&api.StoreKVPairs{
+			BlockHeight: 7,
+			StoreKey:    "bank",
+			KeyPrefix:   []byte{0x02},
+			Pairs: []*api.StoreKVPair{
+				{Key: []byte("a"), Value: []byte("1")},
+				{StoreKey: "acc", Key: []byte("b"), Delete: true},
+			},
+		},
+		&api.StoreKVPairs{
+			BlockHeight: 8,
+			StoreKey:    "bank",
+			Pairs:       []*api.StoreKVPair{{Key: []byte("c"), Value: []byte("2")}},
+		},
+	}
+	expected := []*api.Node{
+		{StoreKey: "bank", Key: []byte{0x02, 'a'}, Value: []byte("1"), Block: 7},
+		{StoreKey: "acc", Key: []byte{0x02, 'b'}, Delete: true, Block: 7},
+		{StoreKey: "bank", Key: []byte("c"), Value: []byte("2"), Block: 8},
+	}
+
+	for _, prefix := range []ingest.LengthPrefix{ingest.Uvarint, ingest.Uint32LE, ingest.Uint64BE} {
+		t.Run(prefix.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			writeMessages(t, &buf, prefix, batches...)
+			in := make(chan compact.Sequenced)
+			nodes := collect(in)
+			n, err := ingest.ReadBatches(&buf, prefix, in)
+			require.NoError(t, err)
+			require.Equal(t, 3, n)
+			got := nodes()
+			require.Len(t, got, len(expected))
+			for i := range expected {
+				require.True(t, proto.Equal(expected[i], got[i]), "node %d: %v", i, got[i])
+			}
+		})
+	}
+
+	// a stream cut within a message
+	var buf bytes.Buffer
+	writeMessages(t, &buf, ingest.Uvarint, batches...)
+	in := make(chan compact.Sequenced)
+	nodes := collect(in)
+	n, err := ingest.ReadBatches(bytes.NewReader(buf.Bytes()[:buf.Len()-2]), ingest.Uvarint, in)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.Equal(t, 2, n)
+	require.Len(t, nodes(), 2)
+}
+
+func TestReadPairsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pairs.gz")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	zw := gzip.NewWriter(f)
+	for i := 0; i < 100; i++ {
+		writeMessages(t, zw, ingest.Uint32LE, &api.StoreKVPair{
+			StoreKey: "bank",
+			Key:      []byte(fmt.Sprintf("key-%d", i)),
+			Value:    []byte(fmt.Sprintf("value-%d", i)),
+		})
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+
+	outDir := t.TempDir()
+	ctx := &compact.StreamingContext{
+		OutDir:       outDir,
+		MaxFileSize:  1024,
+		OrderedInput: true,
+		In:           make(chan compact.Sequenced),
+	}
+	readErr := make(chan error, 1)
+	go func() {
+		_, err := ingest.ReadPairsFile(path, ingest.Uint32LE, 42, ctx.In)
+		close(ctx.In)
+		readErr <- err
+	}()
+	_, err = ctx.Compact()
+	require.NoError(t, err)
+	require.NoError(t, <-readErr)
+
+	itr, err := compact.NewSequencedIterator(outDir, func() *api.Node { return &api.Node{} })
+	require.NoError(t, err)
+	count := 0
+	for ; itr.Valid(); err = itr.Next() {
+		require.NoError(t, err)
+		require.Equal(t, int64(42), itr.Node.Block)
+		require.Equal(t, "bank", itr.Node.StoreKey)
+		require.Equal(t, fmt.Sprintf("key-%d", count), string(itr.Node.Key))
+		count++
+	}
+	require.NoError(t, err)
+	require.Equal(t, 100, count)
+}
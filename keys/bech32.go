@@ -0,0 +1,139 @@
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Bech32 encoding as specified by BIP-173, which the SDK renders addresses in.
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// ErrInvalidBech32 is returned by Bech32Decode for malformed strings.
+var ErrInvalidBech32 = errors.New("invalid bech32")
+
+var bech32Generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+func bech32Polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= bech32Generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+// convertBits regroups data from groups of fromBits bits into groups of toBits bits.  With pad, a last incomplete
+// group is padded with zeros; without it, the padding left over must be zero and shorter than fromBits.
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var (
+		acc  uint32
+		bits uint
+		out  []byte
+	)
+	maxValue := uint32(1)<<toBits - 1
+	for _, b := range data {
+		if uint32(b)>>fromBits != 0 {
+			return nil, fmt.Errorf("%w: value %d does not fit in %d bits", ErrInvalidBech32, b, fromBits)
+		}
+		acc = acc<<fromBits | uint32(b)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxValue))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(toBits-bits)&maxValue))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxValue != 0 {
+		return nil, fmt.Errorf("%w: invalid padding", ErrInvalidBech32)
+	}
+	return out, nil
+}
+
+// Bech32Encode renders data, such as an address, as a bech32 string with the human readable part hrp.
+func Bech32Encode(hrp string, data []byte) (string, error) {
+	if hrp == "" {
+		return "", fmt.Errorf("%w: empty human readable part", ErrInvalidBech32)
+	}
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", fmt.Errorf("%w: invalid character in human readable part %q", ErrInvalidBech32, hrp)
+		}
+	}
+	hrp = strings.ToLower(hrp)
+	data5, err := convertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	values := append(bech32HRPExpand(hrp), data5...)
+	polymod := bech32Polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ 1
+
+	var sb strings.Builder
+	sb.Grow(len(hrp) + 1 + len(data5) + 6)
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, v := range data5 {
+		sb.WriteByte(bech32Charset[v])
+	}
+	for i := 0; i < 6; i++ {
+		sb.WriteByte(bech32Charset[(polymod>>(5*(5-i)))&31])
+	}
+	return sb.String(), nil
+}
+
+// Bech32Decode parses a bech32 string into its human readable part and data.  Unlike BIP-173 no length limit is
+// applied, since the SDK renders 32 byte addresses too.
+func Bech32Decode(s string) (hrp string, data []byte, err error) {
+	lower, upper := strings.ToLower(s), strings.ToUpper(s)
+	if s != lower && s != upper {
+		return "", nil, fmt.Errorf("%w: mixed case", ErrInvalidBech32)
+	}
+	s = lower
+	sep := strings.LastIndexByte(s, '1')
+	if sep < 1 || sep+7 > len(s) {
+		return "", nil, fmt.Errorf("%w: separator at %d of %d characters", ErrInvalidBech32, sep, len(s))
+	}
+	hrp = s[:sep]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, fmt.Errorf("%w: invalid character in human readable part", ErrInvalidBech32)
+		}
+	}
+	data5 := make([]byte, 0, len(s)-sep-1)
+	for i := sep + 1; i < len(s); i++ {
+		v := strings.IndexByte(bech32Charset, s[i])
+		if v < 0 {
+			return "", nil, fmt.Errorf("%w: invalid character %q", ErrInvalidBech32, s[i])
+		}
+		data5 = append(data5, byte(v))
+	}
+	if bech32Polymod(append(bech32HRPExpand(hrp), data5...)) != 1 {
+		return "", nil, fmt.Errorf("%w: checksum", ErrInvalidBech32)
+	}
+	data, err = convertBits(data5[:len(data5)-6], 5, 8, false)
+	if err != nil {
+		return "", nil, err
+	}
+	return hrp, data, nil
+}
@@ -0,0 +1,280 @@
+// Package keys describes the layouts of Cosmos SDK store keys: a fixed prefix followed by parts such as length
+// prefixed addresses, big endian integers and strings.  A Layout encodes, decodes and renders keys for humans.
+package keys
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrLayout is returned when a key does not fit a layout.
+var ErrLayout = errors.New("key does not fit layout")
+
+// Codec encodes and decodes one part of a key.
+type Codec[T any] interface {
+	// Encode appends the encoding of v to bz.
+	Encode(bz []byte, v T) ([]byte, error)
+	// Decode reads a value from the start of bz and returns it with the number of bytes read.
+	Decode(bz []byte) (T, int, error)
+	// Format renders v for humans.
+	Format(v T) string
+}
+
+type bytesCodec struct{}
+
+// Bytes is the rest of the key, as is.
+var Bytes Codec[[]byte] = bytesCodec{}
+
+func (bytesCodec) Encode(bz []byte, v []byte) ([]byte, error) { return append(bz, v...), nil }
+func (bytesCodec) Decode(bz []byte) ([]byte, int, error)      { return bz, len(bz), nil }
+func (bytesCodec) Format(v []byte) string                     { return strings.ToUpper(hex.EncodeToString(v)) }
+
+type stringCodec struct{}
+
+// String is the rest of the key as a string, such as a denom.
+var String Codec[string] = stringCodec{}
+
+func (stringCodec) Encode(bz []byte, v string) ([]byte, error) { return append(bz, v...), nil }
+func (stringCodec) Decode(bz []byte) (string, int, error)      { return string(bz), len(bz), nil }
+func (stringCodec) Format(v string) string                     { return v }
+
+type lengthPrefixedCodec struct{}
+
+// LengthPrefixed is a byte string preceded by its length in one byte.
+var LengthPrefixed Codec[[]byte] = lengthPrefixedCodec{}
+
+func (lengthPrefixedCodec) Encode(bz []byte, v []byte) ([]byte, error) {
+	if len(v) > 255 {
+		return nil, fmt.Errorf("%d bytes do not fit a one byte length prefix", len(v))
+	}
+	return append(append(bz, byte(len(v))), v...), nil
+}
+
+func (lengthPrefixedCodec) Decode(bz []byte) ([]byte, int, error) {
+	if len(bz) == 0 {
+		return nil, 0, errors.New("missing length prefix")
+	}
+	n := int(bz[0])
+	if len(bz) < 1+n {
+		return nil, 0, fmt.Errorf("length prefix %d exceeds the %d bytes left in the key", n, len(bz)-1)
+	}
+	return bz[1 : 1+n], 1 + n, nil
+}
+
+func (lengthPrefixedCodec) Format(v []byte) string { return strings.ToUpper(hex.EncodeToString(v)) }
+
+type addressCodec struct {
+	hrp            string
+	lengthPrefixed bool
+}
+
+// Address is a length prefixed address, as the SDK's address.MustLengthPrefix writes addresses into keys.  It is
+// rendered in bech32 with the human readable part hrp, or in hex if hrp is empty.
+func Address(hrp string) Codec[[]byte] {
+	return addressCodec{hrp: hrp, lengthPrefixed: true}
+}
+
+// RawAddress is an address which takes up the rest of the key, rendered as Address renders.
+func RawAddress(hrp string) Codec[[]byte] {
+	return addressCodec{hrp: hrp}
+}
+
+func (c addressCodec) Encode(bz []byte, v []byte) ([]byte, error) {
+	if c.lengthPrefixed {
+		return LengthPrefixed.Encode(bz, v)
+	}
+	return Bytes.Encode(bz, v)
+}
+
+func (c addressCodec) Decode(bz []byte) ([]byte, int, error) {
+	if c.lengthPrefixed {
+		return LengthPrefixed.Decode(bz)
+	}
+	if len(bz) == 0 {
+		return nil, 0, errors.New("missing address")
+	}
+	return Bytes.Decode(bz)
+}
+
+func (c addressCodec) Format(v []byte) string {
+	return FormatAddress(c.hrp, v)
+}
+
+// FormatAddress renders addr in bech32 with the human readable part hrp, or in upper case hex if hrp is empty.
+func FormatAddress(hrp string, addr []byte) string {
+	if hrp == "" {
+		return strings.ToUpper(hex.EncodeToString(addr))
+	}
+	s, err := Bech32Encode(hrp, addr)
+	if err != nil {
+		return strings.ToUpper(hex.EncodeToString(addr))
+	}
+	return s
+}
+
+type uint64Codec struct{}
+
+// Uint64 is a big endian uint64, as heights, sequences and ids are written into keys so that they sort.
+var Uint64 Codec[uint64] = uint64Codec{}
+
+func (uint64Codec) Encode(bz []byte, v uint64) ([]byte, error) {
+	return binary.BigEndian.AppendUint64(bz, v), nil
+}
+
+func (uint64Codec) Decode(bz []byte) (uint64, int, error) {
+	if len(bz) < 8 {
+		return 0, 0, fmt.Errorf("%d bytes left for a uint64", len(bz))
+	}
+	return binary.BigEndian.Uint64(bz), 8, nil
+}
+
+func (uint64Codec) Format(v uint64) string { return strconv.FormatUint(v, 10) }
+
+// Part is a named part of a layout.
+type Part interface {
+	Name() string
+	encode(bz []byte, v any) ([]byte, error)
+	decode(bz []byte) (any, int, error)
+	format(v any) string
+}
+
+type part[T any] struct {
+	name  string
+	codec Codec[T]
+}
+
+// Field returns a part named name encoded by codec.
+func Field[T any](name string, codec Codec[T]) Part {
+	return part[T]{name: name, codec: codec}
+}
+
+func (p part[T]) Name() string { return p.name }
+
+func (p part[T]) encode(bz []byte, v any) ([]byte, error) {
+	t, ok := v.(T)
+	if !ok {
+		return nil, fmt.Errorf("part %s: %T is not a %T", p.name, v, t)
+	}
+	return p.codec.Encode(bz, t)
+}
+
+func (p part[T]) decode(bz []byte) (any, int, error) {
+	return p.codec.Decode(bz)
+}
+
+func (p part[T]) format(v any) string {
+	return p.codec.Format(v.(T))
+}
+
+// Layout is the layout of the keys under one prefix of a store.
+type Layout struct {
+	// HumanPrefix names the prefix, such as "Balances", for api.DecodeError.HumanPrefix.
+	HumanPrefix string
+	Prefix      []byte
+	Parts       []Part
+}
+
+func NewLayout(humanPrefix string, prefix []byte, parts ...Part) *Layout {
+	return &Layout{HumanPrefix: humanPrefix, Prefix: prefix, Parts: parts}
+}
+
+// Value is a decoded part of a key.
+type Value struct {
+	Name  string
+	Value any
+	// Text is Value rendered for humans.
+	Text string
+}
+
+// Match returns true if key starts with the layout's prefix.
+func (l *Layout) Match(key []byte) bool {
+	return bytes.HasPrefix(key, l.Prefix)
+}
+
+// Encode builds a key from the prefix and one value per part.
+func (l *Layout) Encode(values ...any) ([]byte, error) {
+	if len(values) != len(l.Parts) {
+		return nil, fmt.Errorf("%s has %d parts, got %d values", l.HumanPrefix, len(l.Parts), len(values))
+	}
+	bz := append([]byte(nil), l.Prefix...)
+	var err error
+	for i, p := range l.Parts {
+		if bz, err = p.encode(bz, values[i]); err != nil {
+			return nil, fmt.Errorf("%s: %w", l.HumanPrefix, err)
+		}
+	}
+	return bz, nil
+}
+
+// Decode splits key into its parts.  The key must start with the prefix and hold nothing after the last part.
+func (l *Layout) Decode(key []byte) ([]Value, error) {
+	if !l.Match(key) {
+		return nil, fmt.Errorf("%w %s: prefix %X", ErrLayout, l.HumanPrefix, l.Prefix)
+	}
+	return l.DecodeParts(key[len(l.Prefix):])
+}
+
+// DecodeParts is Decode for a key without its prefix.
+func (l *Layout) DecodeParts(bz []byte) ([]Value, error) {
+	values := make([]Value, len(l.Parts))
+	for i, p := range l.Parts {
+		v, n, err := p.decode(bz)
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %s: %v", ErrLayout, l.HumanPrefix, p.Name(), err)
+		}
+		values[i] = Value{Name: p.Name(), Value: v, Text: p.format(v)}
+		bz = bz[n:]
+	}
+	if len(bz) > 0 {
+		return nil, fmt.Errorf("%w %s: %d bytes after the last part", ErrLayout, l.HumanPrefix, len(bz))
+	}
+	return values, nil
+}
+
+// Format renders key for humans as the human prefix followed by each part, separated by '/'.  Whatever does not fit
+// the layout is rendered in hex.
+func (l *Layout) Format(key []byte) string {
+	if !l.Match(key) {
+		return strings.ToUpper(hex.EncodeToString(key))
+	}
+	var sb strings.Builder
+	sb.WriteString(l.HumanPrefix)
+	bz := key[len(l.Prefix):]
+	for _, p := range l.Parts {
+		v, n, err := p.decode(bz)
+		if err != nil {
+			break
+		}
+		sb.WriteString("/")
+		sb.WriteString(p.format(v))
+		bz = bz[n:]
+	}
+	if len(bz) > 0 {
+		sb.WriteString("/")
+		sb.WriteString(strings.ToUpper(hex.EncodeToString(bz)))
+	}
+	return sb.String()
+}
+
+// Describe returns the human prefix of the layout with the longest prefix matching key, or the key's first byte in
+// hex, such as 0x02, if none does.
+func Describe(key []byte, layouts ...*Layout) string {
+	var match *Layout
+	for _, l := range layouts {
+		if l.Match(key) && (match == nil || len(l.Prefix) > len(match.Prefix)) {
+			match = l
+		}
+	}
+	switch {
+	case match != nil:
+		return match.HumanPrefix
+	case len(key) == 0:
+		return ""
+	}
+	return fmt.Sprintf("0x%02X", key[0])
+}
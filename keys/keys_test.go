@@ -0,0 +1,104 @@
+package keys_test
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/kocubinski/costor-api/keys"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBech32(t *testing.T) {
+	// module account addresses are the first 20 bytes of the sha256 of the module name
+	moduleAccounts := map[string]string{
+		"fee_collector":      "cosmos17xpfvakm2amg962yls6f84z3kell8c5lserqta",
+		"distribution":       "cosmos1jv65s3grqf6v6jl3dp4t6c9t9rk99cd88lyufl",
+		"bonded_tokens_pool": "cosmos1fl48vsnmsdzcv85q5d2q4z5ajdha8yu34mf0eh",
+		"gov":                "cosmos10d07y265gmmuvt4z0w9aw880jnsr700j6zn9kn",
+	}
+	for name, expected := range moduleAccounts {
+		hash := sha256.Sum256([]byte(name))
+		addr := hash[:20]
+		s, err := keys.Bech32Encode("cosmos", addr)
+		require.NoError(t, err)
+		require.Equal(t, expected, s, name)
+
+		hrp, data, err := keys.Bech32Decode(expected)
+		require.NoError(t, err)
+		require.Equal(t, "cosmos", hrp)
+		require.Equal(t, addr, data)
+	}
+
+	// BIP-173 valid strings
+	for _, s := range []string{
+		"A12UEL5L",
+		"a12uel5l",
+		"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+		"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w",
+	} {
+		_, _, err := keys.Bech32Decode(s)
+		require.NoError(t, err, s)
+	}
+	// BIP-173 invalid strings
+	for _, s := range []string{
+		"pzry9x0s0muk",
+		"1pzry9x0s0muk",
+		"x1b4n0q5v",
+		"li1dgmt3",
+		"A1G7SGD8",
+		"10a06t8",
+		"1qzzfhee",
+		"cosmos17xpfvakm2amg962yls6f84z3kell8c5lserqtA",
+		"cosmos17xpfvakm2amg962yls6f84z3kell8c5lserqtb",
+	} {
+		_, _, err := keys.Bech32Decode(s)
+		require.ErrorIs(t, err, keys.ErrInvalidBech32, s)
+	}
+}
+
+func TestLayout(t *testing.T) {
+	addr := []byte{0xde, 0xad, 0xbe, 0xef}
+	balances := keys.NewLayout("Balances", []byte{0x02},
+		keys.Field("address", keys.Address("cosmos")),
+		keys.Field("denom", keys.String),
+	)
+	key, err := balances.Encode(addr, "uatom")
+	require.NoError(t, err)
+	require.Equal(t, append([]byte{0x02, 0x04, 0xde, 0xad, 0xbe, 0xef}, "uatom"...), key)
+
+	values, err := balances.Decode(key)
+	require.NoError(t, err)
+	require.Len(t, values, 2)
+	require.Equal(t, "address", values[0].Name)
+	require.Equal(t, addr, values[0].Value)
+	bech32Addr, err := keys.Bech32Encode("cosmos", addr)
+	require.NoError(t, err)
+	require.Equal(t, bech32Addr, values[0].Text)
+	require.Equal(t, "uatom", values[1].Value)
+	require.Equal(t, "Balances/"+bech32Addr+"/uatom", balances.Format(key))
+
+	_, err = balances.Encode(addr)
+	require.Error(t, err)
+	_, err = balances.Encode("not bytes", "uatom")
+	require.Error(t, err)
+	_, err = balances.Decode([]byte{0x02, 0x10, 0x01})
+	require.ErrorIs(t, err, keys.ErrLayout)
+	require.Equal(t, "Balances/1001", balances.Format([]byte{0x02, 0x10, 0x01}))
+
+	heights := keys.NewLayout("Heights", []byte{0x03},
+		keys.Field("height", keys.Uint64),
+		keys.Field("id", keys.LengthPrefixed),
+	)
+	key, err = heights.Encode(uint64(258), []byte{0x01})
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x03, 0, 0, 0, 0, 0, 0, 1, 2, 1, 1}, key)
+	require.Equal(t, "Heights/258/01", heights.Format(key))
+	_, err = heights.Decode(append(key, 0xff))
+	require.ErrorIs(t, err, keys.ErrLayout)
+
+	supply := keys.NewLayout("Supply", []byte{0x00}, keys.Field("denom", keys.String))
+	require.Equal(t, "", keys.Describe(nil, balances, supply, heights))
+	require.Equal(t, "Balances", keys.Describe([]byte{0x02, 0x00}, balances, supply, heights))
+	require.Equal(t, "Heights", keys.Describe([]byte{0x03}, balances, supply, heights))
+	require.Equal(t, "0x05", keys.Describe([]byte{0x05, 0x01}, balances, supply, heights))
+}
@@ -0,0 +1,403 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.31.0
+// 	protoc        v4.24.2
+// source: listener.proto
+
+package api
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ListenerMessage is sent by a node to a costor state listener.  For every block the node sends the block's changes,
+// in one or more BlockChanges messages, followed by a BlockCommit, which the listener acknowledges with a ListenerAck.
+type ListenerMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Message:
+	//	*ListenerMessage_Changes
+	//	*ListenerMessage_Commit
+	Message isListenerMessage_Message `protobuf_oneof:"message"`
+}
+
+func (x *ListenerMessage) Reset() {
+	*x = ListenerMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_listener_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListenerMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListenerMessage) ProtoMessage() {}
+
+func (x *ListenerMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_listener_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListenerMessage.ProtoReflect.Descriptor instead.
+func (*ListenerMessage) Descriptor() ([]byte, []int) {
+	return file_listener_proto_rawDescGZIP(), []int{0}
+}
+
+func (m *ListenerMessage) GetMessage() isListenerMessage_Message {
+	if m != nil {
+		return m.Message
+	}
+	return nil
+}
+
+func (x *ListenerMessage) GetChanges() *BlockChanges {
+	if x, ok := x.GetMessage().(*ListenerMessage_Changes); ok {
+		return x.Changes
+	}
+	return nil
+}
+
+func (x *ListenerMessage) GetCommit() *BlockCommit {
+	if x, ok := x.GetMessage().(*ListenerMessage_Commit); ok {
+		return x.Commit
+	}
+	return nil
+}
+
+type isListenerMessage_Message interface {
+	isListenerMessage_Message()
+}
+
+type ListenerMessage_Changes struct {
+	Changes *BlockChanges `protobuf:"bytes,1,opt,name=changes,proto3,oneof"`
+}
+
+type ListenerMessage_Commit struct {
+	Commit *BlockCommit `protobuf:"bytes,2,opt,name=commit,proto3,oneof"`
+}
+
+func (*ListenerMessage_Changes) isListenerMessage_Message() {}
+
+func (*ListenerMessage_Commit) isListenerMessage_Message() {}
+
+type BlockChanges struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	BlockHeight int64          `protobuf:"varint,1,opt,name=block_height,json=blockHeight,proto3" json:"block_height,omitempty"`
+	Pairs       []*StoreKVPair `protobuf:"bytes,2,rep,name=pairs,proto3" json:"pairs,omitempty"`
+}
+
+func (x *BlockChanges) Reset() {
+	*x = BlockChanges{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_listener_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BlockChanges) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockChanges) ProtoMessage() {}
+
+func (x *BlockChanges) ProtoReflect() protoreflect.Message {
+	mi := &file_listener_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockChanges.ProtoReflect.Descriptor instead.
+func (*BlockChanges) Descriptor() ([]byte, []int) {
+	return file_listener_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *BlockChanges) GetBlockHeight() int64 {
+	if x != nil {
+		return x.BlockHeight
+	}
+	return 0
+}
+
+func (x *BlockChanges) GetPairs() []*StoreKVPair {
+	if x != nil {
+		return x.Pairs
+	}
+	return nil
+}
+
+type BlockCommit struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	BlockHeight int64 `protobuf:"varint,1,opt,name=block_height,json=blockHeight,proto3" json:"block_height,omitempty"`
+}
+
+func (x *BlockCommit) Reset() {
+	*x = BlockCommit{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_listener_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BlockCommit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockCommit) ProtoMessage() {}
+
+func (x *BlockCommit) ProtoReflect() protoreflect.Message {
+	mi := &file_listener_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockCommit.ProtoReflect.Descriptor instead.
+func (*BlockCommit) Descriptor() ([]byte, []int) {
+	return file_listener_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *BlockCommit) GetBlockHeight() int64 {
+	if x != nil {
+		return x.BlockHeight
+	}
+	return 0
+}
+
+// ListenerAck acknowledges a BlockCommit.  error is set if the listener rejected the block, after which it closes the
+// connection.
+type ListenerAck struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	BlockHeight int64  `protobuf:"varint,1,opt,name=block_height,json=blockHeight,proto3" json:"block_height,omitempty"`
+	Error       string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (x *ListenerAck) Reset() {
+	*x = ListenerAck{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_listener_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListenerAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListenerAck) ProtoMessage() {}
+
+func (x *ListenerAck) ProtoReflect() protoreflect.Message {
+	mi := &file_listener_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListenerAck.ProtoReflect.Descriptor instead.
+func (*ListenerAck) Descriptor() ([]byte, []int) {
+	return file_listener_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListenerAck) GetBlockHeight() int64 {
+	if x != nil {
+		return x.BlockHeight
+	}
+	return 0
+}
+
+func (x *ListenerAck) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+var File_listener_proto protoreflect.FileDescriptor
+
+var file_listener_proto_rawDesc = []byte{
+	0x0a, 0x0e, 0x6c, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x65, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x1a, 0x0b, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x6f, 0x0a,
+	0x0f, 0x4c, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x12, 0x29, 0x0a, 0x07, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x0d, 0x2e, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x73,
+	0x48, 0x00, 0x52, 0x07, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x12, 0x26, 0x0a, 0x06, 0x63,
+	0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x42, 0x6c,
+	0x6f, 0x63, 0x6b, 0x43, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x48, 0x00, 0x52, 0x06, 0x63, 0x6f, 0x6d,
+	0x6d, 0x69, 0x74, 0x42, 0x09, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x55,
+	0x0a, 0x0c, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x73, 0x12, 0x21,
+	0x0a, 0x0c, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x48, 0x65, 0x69, 0x67, 0x68,
+	0x74, 0x12, 0x22, 0x0a, 0x05, 0x70, 0x61, 0x69, 0x72, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x0c, 0x2e, 0x53, 0x74, 0x6f, 0x72, 0x65, 0x4b, 0x56, 0x50, 0x61, 0x69, 0x72, 0x52, 0x05,
+	0x70, 0x61, 0x69, 0x72, 0x73, 0x22, 0x30, 0x0a, 0x0b, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x43, 0x6f,
+	0x6d, 0x6d, 0x69, 0x74, 0x12, 0x21, 0x0a, 0x0c, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x68, 0x65,
+	0x69, 0x67, 0x68, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x62, 0x6c, 0x6f, 0x63,
+	0x6b, 0x48, 0x65, 0x69, 0x67, 0x68, 0x74, 0x22, 0x46, 0x0a, 0x0b, 0x4c, 0x69, 0x73, 0x74, 0x65,
+	0x6e, 0x65, 0x72, 0x41, 0x63, 0x6b, 0x12, 0x21, 0x0a, 0x0c, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f,
+	0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x62, 0x6c,
+	0x6f, 0x63, 0x6b, 0x48, 0x65, 0x69, 0x67, 0x68, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x72, 0x72,
+	0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x42,
+	0x26, 0x5a, 0x24, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6b, 0x6f,
+	0x63, 0x75, 0x62, 0x69, 0x6e, 0x73, 0x6b, 0x69, 0x2f, 0x63, 0x6f, 0x73, 0x74, 0x6f, 0x72, 0x2d,
+	0x61, 0x70, 0x69, 0x2f, 0x61, 0x70, 0x69, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_listener_proto_rawDescOnce sync.Once
+	file_listener_proto_rawDescData = file_listener_proto_rawDesc
+)
+
+func file_listener_proto_rawDescGZIP() []byte {
+	file_listener_proto_rawDescOnce.Do(func() {
+		file_listener_proto_rawDescData = protoimpl.X.CompressGZIP(file_listener_proto_rawDescData)
+	})
+	return file_listener_proto_rawDescData
+}
+
+var file_listener_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_listener_proto_goTypes = []interface{}{
+	(*ListenerMessage)(nil), // 0: ListenerMessage
+	(*BlockChanges)(nil),    // 1: BlockChanges
+	(*BlockCommit)(nil),     // 2: BlockCommit
+	(*ListenerAck)(nil),     // 3: ListenerAck
+	(*StoreKVPair)(nil),     // 4: StoreKVPair
+}
+var file_listener_proto_depIdxs = []int32{
+	1, // 0: ListenerMessage.changes:type_name -> BlockChanges
+	2, // 1: ListenerMessage.commit:type_name -> BlockCommit
+	4, // 2: BlockChanges.pairs:type_name -> StoreKVPair
+	3, // [3:3] is the sub-list for method output_type
+	3, // [3:3] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_listener_proto_init() }
+func file_listener_proto_init() {
+	if File_listener_proto != nil {
+		return
+	}
+	file_store_proto_init()
+	if !protoimpl.UnsafeEnabled {
+		file_listener_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListenerMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_listener_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BlockChanges); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_listener_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BlockCommit); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_listener_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListenerAck); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_listener_proto_msgTypes[0].OneofWrappers = []interface{}{
+		(*ListenerMessage_Changes)(nil),
+		(*ListenerMessage_Commit)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_listener_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_listener_proto_goTypes,
+		DependencyIndexes: file_listener_proto_depIdxs,
+		MessageInfos:      file_listener_proto_msgTypes,
+	}.Build()
+	File_listener_proto = out.File
+	file_listener_proto_rawDesc = nil
+	file_listener_proto_goTypes = nil
+	file_listener_proto_depIdxs = nil
+}
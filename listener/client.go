@@ -0,0 +1,76 @@
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/ingest"
+)
+
+// ErrRejected is returned by Client.Commit when the listener rejects a block.
+var ErrRejected = errors.New("block rejected by listener")
+
+// Client is the node's side of the listener protocol.  It stands in for a node in tests, and is what the state
+// streaming plugin in cmd/costor-plugin wraps: its ListenCommit has the shape of the SDK's ABCIListener.ListenCommit.
+type Client struct {
+	conn net.Conn
+	r    *ingest.Reader
+	w    *ingest.Writer
+}
+
+// Dial connects to the listener's Unix socket at path.
+func Dial(path string) (*Client, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		conn: conn,
+		r:    ingest.NewReader(conn, ingest.Uvarint),
+		w:    ingest.NewWriter(conn, ingest.Uvarint),
+	}, nil
+}
+
+// SendChanges sends changes of the block at height.  It may be called any number of times before Commit.
+func (c *Client) SendChanges(height int64, pairs []*api.StoreKVPair) error {
+	return c.w.WriteMessage(&api.ListenerMessage{Message: &api.ListenerMessage_Changes{
+		Changes: &api.BlockChanges{BlockHeight: height, Pairs: pairs},
+	}})
+}
+
+// Commit commits the block at height and waits for the listener to acknowledge it.
+func (c *Client) Commit(height int64) error {
+	err := c.w.WriteMessage(&api.ListenerMessage{Message: &api.ListenerMessage_Commit{
+		Commit: &api.BlockCommit{BlockHeight: height},
+	}})
+	if err != nil {
+		return err
+	}
+	ack := &api.ListenerAck{}
+	if err := c.r.ReadMessage(ack); err != nil {
+		return fmt.Errorf("waiting for ack of block %d: %w", height, err)
+	}
+	if ack.Error != "" {
+		return fmt.Errorf("%w: block %d: %s", ErrRejected, ack.BlockHeight, ack.Error)
+	}
+	if ack.BlockHeight != height {
+		return fmt.Errorf("ack for block %d while committing block %d", ack.BlockHeight, height)
+	}
+	return nil
+}
+
+// ListenCommit sends the change set of the block at height and commits it.
+func (c *Client) ListenCommit(height int64, changeSet []*api.StoreKVPair) error {
+	if len(changeSet) > 0 {
+		if err := c.SendChanges(height, changeSet); err != nil {
+			return err
+		}
+	}
+	return c.Commit(height)
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,278 @@
+// Package listener receives state changes from a running node over a Unix socket and writes them straight into a
+// compact.StreamingContext, so no intermediate file format is needed between the chain and costor.
+//
+// Messages in both directions are protobuf, each prefixed with its length as an unsigned varint.  The node sends
+// api.ListenerMessage: for every block, the block's changes in one or more BlockChanges followed by a BlockCommit.
+// The listener answers every BlockCommit with an api.ListenerAck once the block's nodes have been handed to the
+// StreamingContext.
+//
+// A node sends its blocks through the go-plugin state streaming plugin built from cmd/costor-plugin, a module of its
+// own so that costor does not depend on the SDK.
+package listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/compact"
+	"github.com/kocubinski/costor-api/ingest"
+	"github.com/kocubinski/costor-api/logz"
+)
+
+var log = logz.Logger.With().Str("module", "listener").Logger()
+
+// Server accepts node connections on a Unix socket.  Connections are served one at a time so that blocks reach In in
+// commit order; a node reconnecting after a restart may resend blocks which were already acknowledged, which are
+// acknowledged again and otherwise ignored.
+//
+// Serve may be cancelled part way through handing a block to In, which leaves the first nodes of the block in the
+// output without an acknowledgement.  A Server started on the same output should Resume from it, so that the block
+// the node sends again is only completed.
+type Server struct {
+	In chan<- compact.Sequenced
+
+	listener net.Listener
+	// lastCommit is the height of the last block handed to In in full
+	lastCommit int64
+	// partial is the height of a block of which only the first partialSent nodes may be in the output, as Resume
+	// found it.  It is only used by the serving goroutine.
+	partial     int64
+	partialSent int
+
+	mu     sync.Mutex
+	closed bool
+	conn   net.Conn
+}
+
+// Listen creates the Unix socket at path, replacing a stale socket left by a previous run, and returns a Server which
+// sends the nodes it receives to in.
+func Listen(path string, in chan<- compact.Sequenced) (*Server, error) {
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{In: in, listener: l}, nil
+}
+
+// Addr returns the address of the socket.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Resume seeds the server with the output in dir of a previous server, a directory of api.Node segments written in
+// order.  Blocks up to the last one in dir are taken as handed over, and the last one as handed over up to the nodes
+// found; if the node sends it again, only the rest of its changes are sent to In.  This relies on a node sending the
+// changes of a block in the same order every time, as the SDK's streaming does.  Resume must be called before Serve.
+func (s *Server) Resume(dir string) error {
+	height, count, err := lastBlock(dir)
+	if err != nil || height == 0 {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastCommit = height - 1
+	s.partial, s.partialSent = height, count
+	return nil
+}
+
+// lastBlock returns the height of the last block in the segments of dir and its number of nodes, reading segments from
+// the last one back while the block continues into them.
+func lastBlock(dir string) (height int64, count int, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".pb.gz") {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	for i := len(files) - 1; i >= 0; i-- {
+		var (
+			first, last int64 = -1, -1
+			run         int
+		)
+		itr, err := compact.NewSegmentIterator(files[i], func() *api.Node { return &api.Node{} }, compact.WithReuseNode())
+		for ; err == nil && itr.Valid(); err = itr.Next() {
+			if first < 0 {
+				first = itr.Node.Block
+			}
+			if itr.Node.Block != last {
+				last, run = itr.Node.Block, 0
+			}
+			run++
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		switch {
+		case last < 0:
+			// an empty segment
+			continue
+		case height == 0:
+			height = last
+		case last != height:
+			return height, count, nil
+		}
+		count += run
+		if first != height {
+			return height, count, nil
+		}
+	}
+	return height, count, nil
+}
+
+// LastCommit returns the height of the last block sent to In in full.
+func (s *Server) LastCommit() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastCommit
+}
+
+// Serve accepts and serves connections until ctx is done or the server is closed, and then returns nil.  Serve does
+// not close In.
+func (s *Server) Serve(ctx context.Context) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = s.Close()
+		case <-done:
+		}
+	}()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return nil
+			}
+			return err
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return nil
+		}
+		s.conn = conn
+		s.mu.Unlock()
+		if err := s.serveConn(ctx, conn); err != nil && !s.isClosed() {
+			log.Warn().Err(err).Msg("connection closed")
+		}
+		_ = conn.Close()
+	}
+}
+
+// Close stops the server and closes the current connection, if any.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if s.conn != nil {
+		_ = s.conn.Close()
+	}
+	return s.listener.Close()
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) error {
+	r := ingest.NewReader(conn, ingest.Uvarint)
+	w := ingest.NewWriter(conn, ingest.Uvarint)
+	var (
+		height  int64
+		pending []*api.StoreKVPair
+	)
+	for {
+		msg := &api.ListenerMessage{}
+		if err := r.ReadMessage(msg); err == io.EOF {
+			if len(pending) > 0 {
+				return fmt.Errorf("connection closed before block %d was committed", height)
+			}
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		switch m := msg.Message.(type) {
+		case *api.ListenerMessage_Changes:
+			if len(pending) > 0 && m.Changes.BlockHeight != height {
+				return s.reject(w, m.Changes.BlockHeight,
+					fmt.Errorf("changes for block %d before block %d was committed", m.Changes.BlockHeight, height))
+			}
+			height = m.Changes.BlockHeight
+			pending = append(pending, m.Changes.Pairs...)
+
+		case *api.ListenerMessage_Commit:
+			commit := m.Commit.BlockHeight
+			if len(pending) > 0 && commit != height {
+				return s.reject(w, commit, fmt.Errorf("commit of block %d with changes for block %d", commit, height))
+			}
+			if last := s.LastCommit(); commit <= last {
+				log.Warn().Msgf("ignoring block %d which was committed before, last commit %d", commit, last)
+			} else {
+				skip := 0
+				if commit == s.partial {
+					skip = s.partialSent
+					if skip > len(pending) {
+						return s.reject(w, commit, fmt.Errorf("block %d has %d changes, %d were received before",
+							commit, len(pending), skip))
+					}
+					log.Info().Msgf("completing block %d after its first %d changes", commit, skip)
+				}
+				// a later block means the partial one was acknowledged, and so complete
+				s.partial, s.partialSent = 0, 0
+				for _, pair := range pending[skip:] {
+					select {
+					case s.In <- ingest.PairNode(pair, commit):
+					case <-ctx.Done():
+						// the block is not acknowledged, so the node will send it again
+						return ctx.Err()
+					}
+				}
+				s.mu.Lock()
+				s.lastCommit = commit
+				s.mu.Unlock()
+			}
+			pending = nil
+			if err := w.WriteMessage(&api.ListenerAck{BlockHeight: commit}); err != nil {
+				return err
+			}
+
+		default:
+			return s.reject(w, height, errors.New("empty message"))
+		}
+	}
+}
+
+// reject tells the node why its block was rejected and returns err, which ends the connection.
+func (s *Server) reject(w *ingest.Writer, height int64, err error) error {
+	if werr := w.WriteMessage(&api.ListenerAck{BlockHeight: height, Error: err.Error()}); werr != nil {
+		return errors.Join(err, werr)
+	}
+	return err
+}
@@ -0,0 +1,208 @@
+package listener_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	api "github.com/kocubinski/costor-api"
+	"github.com/kocubinski/costor-api/compact"
+	"github.com/kocubinski/costor-api/listener"
+	"github.com/stretchr/testify/require"
+)
+
+func pairs(height int64, n int) []*api.StoreKVPair {
+	var pairs []*api.StoreKVPair
+	for i := 0; i < n; i++ {
+		pairs = append(pairs, &api.StoreKVPair{
+			StoreKey: "bank",
+			Key:      []byte(fmt.Sprintf("key-%d-%d", height, i)),
+			Value:    []byte("value"),
+		})
+	}
+	return pairs
+}
+
+func TestListener(t *testing.T) {
+	outDir := t.TempDir()
+	streamCtx := &compact.StreamingContext{
+		OutDir:       outDir,
+		MaxFileSize:  1024,
+		OrderedInput: true,
+		In:           make(chan compact.Sequenced),
+	}
+	compacted := make(chan error)
+	go func() {
+		_, err := streamCtx.Compact()
+		compacted <- err
+	}()
+
+	socket := filepath.Join(t.TempDir(), "costor.sock")
+	server, err := listener.Listen(socket, streamCtx.In)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() {
+		served <- server.Serve(ctx)
+	}()
+
+	client, err := listener.Dial(socket)
+	require.NoError(t, err)
+	for height := int64(1); height <= 20; height++ {
+		require.NoError(t, client.SendChanges(height, pairs(height, 3)))
+		require.NoError(t, client.SendChanges(height, pairs(height, 6)[3:]))
+		require.NoError(t, client.Commit(height))
+	}
+	// a block without changes
+	require.NoError(t, client.ListenCommit(21, nil))
+	require.NoError(t, client.Close())
+
+	// a restarted node resends its last block, which is acknowledged and dropped
+	client, err = listener.Dial(socket)
+	require.NoError(t, err)
+	require.NoError(t, client.ListenCommit(20, pairs(20, 6)))
+	require.NoError(t, client.ListenCommit(22, pairs(22, 6)))
+	// changes must be committed at their own height
+	require.NoError(t, client.SendChanges(23, pairs(23, 1)))
+	require.ErrorIs(t, client.Commit(24), listener.ErrRejected)
+	require.NoError(t, client.Close())
+	require.Equal(t, int64(22), server.LastCommit())
+
+	cancel()
+	require.NoError(t, <-served)
+	close(streamCtx.In)
+	require.NoError(t, <-compacted)
+
+	itr, err := compact.NewSequencedIterator(outDir, func() *api.Node { return &api.Node{} })
+	require.NoError(t, err)
+	count := 0
+	for ; itr.Valid(); err = itr.Next() {
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("key-%d-%d", itr.Node.Block, count%6), string(itr.Node.Key))
+		count++
+	}
+	require.NoError(t, err)
+	require.Equal(t, 21*6, count)
+}
+
+func TestListener_CancelWhileBlocked(t *testing.T) {
+	// nothing reads from in, as when the consumer has stopped
+	in := make(chan compact.Sequenced)
+	socket := filepath.Join(t.TempDir(), "costor.sock")
+	server, err := listener.Listen(socket, in)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() {
+		served <- server.Serve(ctx)
+	}()
+
+	client, err := listener.Dial(socket)
+	require.NoError(t, err)
+	committed := make(chan error)
+	go func() {
+		committed <- client.ListenCommit(1, pairs(1, 3))
+	}()
+	select {
+	case err := <-committed:
+		t.Fatalf("commit returned while the listener was blocked: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	select {
+	case err := <-served:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after ctx was cancelled")
+	}
+	require.Error(t, <-committed)
+	require.Equal(t, int64(0), server.LastCommit())
+	require.NoError(t, client.Close())
+}
+
+func TestListener_Resume(t *testing.T) {
+	outDir := t.TempDir()
+	// serve starts a server on outDir, resumed from it, and returns a function which stops it and waits for its output
+	serve := func(socket string, limit int) (*listener.Server, func()) {
+		streamCtx := &compact.StreamingContext{
+			OutDir:       outDir,
+			MaxFileSize:  1024,
+			OrderedInput: true,
+			In:           make(chan compact.Sequenced),
+		}
+		// at most limit nodes reach Compact, after which the consumer stops reading
+		in := make(chan compact.Sequenced)
+		forwarded := make(chan struct{})
+		go func() {
+			defer close(forwarded)
+			for i := 0; i < limit; i++ {
+				node, ok := <-in
+				if !ok {
+					return
+				}
+				streamCtx.In <- node
+			}
+		}()
+		compacted := make(chan error, 1)
+		go func() {
+			_, err := streamCtx.Compact()
+			compacted <- err
+		}()
+		server, err := listener.Listen(filepath.Join(t.TempDir(), socket), in)
+		require.NoError(t, err)
+		require.NoError(t, server.Resume(outDir))
+		ctx, cancel := context.WithCancel(context.Background())
+		served := make(chan error, 1)
+		go func() {
+			served <- server.Serve(ctx)
+		}()
+		return server, func() {
+			cancel()
+			require.NoError(t, <-served)
+			close(in)
+			<-forwarded
+			close(streamCtx.In)
+			require.NoError(t, <-compacted)
+		}
+	}
+
+	// the consumer stops two nodes into block 2
+	server, stop := serve("first.sock", 6+2)
+	client, err := listener.Dial(server.Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, client.ListenCommit(1, pairs(1, 6)))
+	committed := make(chan error, 1)
+	go func() {
+		committed <- client.ListenCommit(2, pairs(2, 6))
+	}()
+	time.Sleep(50 * time.Millisecond)
+	stop()
+	require.Error(t, <-committed)
+	require.Equal(t, int64(1), server.LastCommit())
+	_ = client.Close()
+
+	// the node sends block 2 again
+	server, stop = serve("second.sock", 1_000)
+	require.Equal(t, int64(1), server.LastCommit())
+	client, err = listener.Dial(server.Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, client.ListenCommit(2, pairs(2, 6)))
+	require.NoError(t, client.ListenCommit(3, pairs(3, 6)))
+	require.NoError(t, client.Close())
+	require.Equal(t, int64(3), server.LastCommit())
+	stop()
+
+	itr, err := compact.NewSequencedIterator(outDir, func() *api.Node { return &api.Node{} })
+	require.NoError(t, err)
+	count := 0
+	for ; itr.Valid(); err = itr.Next() {
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("key-%d-%d", itr.Node.Block, count%6), string(itr.Node.Key))
+		count++
+	}
+	require.NoError(t, err)
+	require.Equal(t, 3*6, count)
+}
@@ -1,7 +1,9 @@
 package api
 
+// IsOrphaned returns true if the write was overwritten or deleted at LastVersion.  It is only meaningful for nodes
+// annotated by compact.Annotate, which sets FirstVersion on every write, live or not.
 func (n *Node) IsOrphaned() bool {
-	return n.FirstVersion > 0
+	return n.LastVersion > 0
 }
 
 func (n *Node) Sequence() int64 {
@@ -20,6 +20,64 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// DecodeErrorCode classifies why a write could not be decoded.
+type DecodeErrorCode int32
+
+const (
+	// DECODE_ERROR_UNSPECIFIED is the code of errors written before codes were recorded.
+	DecodeErrorCode_DECODE_ERROR_UNSPECIFIED DecodeErrorCode = 0
+	// DECODE_ERROR_KEY is a key which does not fit the layout of its prefix.
+	DecodeErrorCode_DECODE_ERROR_KEY DecodeErrorCode = 1
+	// DECODE_ERROR_VALUE is a value which could not be parsed.
+	DecodeErrorCode_DECODE_ERROR_VALUE DecodeErrorCode = 2
+	// DECODE_ERROR_UNKNOWN_TYPE is a value of a type the decoder does not know, such as an Any with an unregistered
+	// type URL.
+	DecodeErrorCode_DECODE_ERROR_UNKNOWN_TYPE DecodeErrorCode = 3
+)
+
+// Enum value maps for DecodeErrorCode.
+var (
+	DecodeErrorCode_name = map[int32]string{
+		0: "DECODE_ERROR_UNSPECIFIED",
+		1: "DECODE_ERROR_KEY",
+		2: "DECODE_ERROR_VALUE",
+		3: "DECODE_ERROR_UNKNOWN_TYPE",
+	}
+	DecodeErrorCode_value = map[string]int32{
+		"DECODE_ERROR_UNSPECIFIED":  0,
+		"DECODE_ERROR_KEY":          1,
+		"DECODE_ERROR_VALUE":        2,
+		"DECODE_ERROR_UNKNOWN_TYPE": 3,
+	}
+)
+
+func (x DecodeErrorCode) Enum() *DecodeErrorCode {
+	p := new(DecodeErrorCode)
+	*p = x
+	return p
+}
+
+func (x DecodeErrorCode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (DecodeErrorCode) Descriptor() protoreflect.EnumDescriptor {
+	return file_node_proto_enumTypes[0].Descriptor()
+}
+
+func (DecodeErrorCode) Type() protoreflect.EnumType {
+	return &file_node_proto_enumTypes[0]
+}
+
+func (x DecodeErrorCode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use DecodeErrorCode.Descriptor instead.
+func (DecodeErrorCode) EnumDescriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{0}
+}
+
 type Node struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -175,10 +233,11 @@ type DecodeError struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Node        *Node  `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
-	StoreKey    string `protobuf:"bytes,2,opt,name=store_key,json=storeKey,proto3" json:"store_key,omitempty"`
-	HumanPrefix string `protobuf:"bytes,3,opt,name=human_prefix,json=humanPrefix,proto3" json:"human_prefix,omitempty"`
-	Reason      string `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	Node        *Node           `protobuf:"bytes,1,opt,name=node,proto3" json:"node,omitempty"`
+	StoreKey    string          `protobuf:"bytes,2,opt,name=store_key,json=storeKey,proto3" json:"store_key,omitempty"`
+	HumanPrefix string          `protobuf:"bytes,3,opt,name=human_prefix,json=humanPrefix,proto3" json:"human_prefix,omitempty"`
+	Reason      string          `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	Code        DecodeErrorCode `protobuf:"varint,5,opt,name=code,proto3,enum=DecodeErrorCode" json:"code,omitempty"`
 }
 
 func (x *DecodeError) Reset() {
@@ -241,6 +300,13 @@ func (x *DecodeError) GetReason() string {
 	return ""
 }
 
+func (x *DecodeError) GetCode() DecodeErrorCode {
+	if x != nil {
+		return x.Code
+	}
+	return DecodeErrorCode_DECODE_ERROR_UNSPECIFIED
+}
+
 var File_node_proto protoreflect.FileDescriptor
 
 var file_node_proto_rawDesc = []byte{
@@ -260,7 +326,7 @@ var file_node_proto_rawDesc = []byte{
 	0x6e, 0x18, 0x08, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x6c, 0x61, 0x73, 0x74, 0x56, 0x65, 0x72,
 	0x73, 0x69, 0x6f, 0x6e, 0x22, 0x24, 0x0a, 0x05, 0x4e, 0x6f, 0x64, 0x65, 0x73, 0x12, 0x1b, 0x0a,
 	0x05, 0x6e, 0x6f, 0x64, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x05, 0x2e, 0x4e,
-	0x6f, 0x64, 0x65, 0x52, 0x05, 0x6e, 0x6f, 0x64, 0x65, 0x73, 0x22, 0x80, 0x01, 0x0a, 0x0b, 0x44,
+	0x6f, 0x64, 0x65, 0x52, 0x05, 0x6e, 0x6f, 0x64, 0x65, 0x73, 0x22, 0xa6, 0x01, 0x0a, 0x0b, 0x44,
 	0x65, 0x63, 0x6f, 0x64, 0x65, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x19, 0x0a, 0x04, 0x6e, 0x6f,
 	0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x05, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x52,
 	0x04, 0x6e, 0x6f, 0x64, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x5f, 0x6b,
@@ -268,10 +334,21 @@ var file_node_proto_rawDesc = []byte{
 	0x65, 0x79, 0x12, 0x21, 0x0a, 0x0c, 0x68, 0x75, 0x6d, 0x61, 0x6e, 0x5f, 0x70, 0x72, 0x65, 0x66,
 	0x69, 0x78, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x68, 0x75, 0x6d, 0x61, 0x6e, 0x50,
 	0x72, 0x65, 0x66, 0x69, 0x78, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18,
-	0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x42, 0x26, 0x5a,
-	0x24, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6b, 0x6f, 0x63, 0x75,
-	0x62, 0x69, 0x6e, 0x73, 0x6b, 0x69, 0x2f, 0x63, 0x6f, 0x73, 0x74, 0x6f, 0x72, 0x2d, 0x61, 0x70,
-	0x69, 0x2f, 0x61, 0x70, 0x69, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12, 0x24, 0x0a,
+	0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x10, 0x2e, 0x44, 0x65,
+	0x63, 0x6f, 0x64, 0x65, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x43, 0x6f, 0x64, 0x65, 0x52, 0x04, 0x63,
+	0x6f, 0x64, 0x65, 0x2a, 0x7c, 0x0a, 0x0f, 0x44, 0x65, 0x63, 0x6f, 0x64, 0x65, 0x45, 0x72, 0x72,
+	0x6f, 0x72, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x1c, 0x0a, 0x18, 0x44, 0x45, 0x43, 0x4f, 0x44, 0x45,
+	0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49,
+	0x45, 0x44, 0x10, 0x00, 0x12, 0x14, 0x0a, 0x10, 0x44, 0x45, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x45,
+	0x52, 0x52, 0x4f, 0x52, 0x5f, 0x4b, 0x45, 0x59, 0x10, 0x01, 0x12, 0x16, 0x0a, 0x12, 0x44, 0x45,
+	0x43, 0x4f, 0x44, 0x45, 0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x5f, 0x56, 0x41, 0x4c, 0x55, 0x45,
+	0x10, 0x02, 0x12, 0x1d, 0x0a, 0x19, 0x44, 0x45, 0x43, 0x4f, 0x44, 0x45, 0x5f, 0x45, 0x52, 0x52,
+	0x4f, 0x52, 0x5f, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x5f, 0x54, 0x59, 0x50, 0x45, 0x10,
+	0x03, 0x42, 0x26, 0x5a, 0x24, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f,
+	0x6b, 0x6f, 0x63, 0x75, 0x62, 0x69, 0x6e, 0x73, 0x6b, 0x69, 0x2f, 0x63, 0x6f, 0x73, 0x74, 0x6f,
+	0x72, 0x2d, 0x61, 0x70, 0x69, 0x2f, 0x61, 0x70, 0x69, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
 }
 
 var (
@@ -286,20 +363,23 @@ func file_node_proto_rawDescGZIP() []byte {
 	return file_node_proto_rawDescData
 }
 
+var file_node_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
 var file_node_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
 var file_node_proto_goTypes = []interface{}{
-	(*Node)(nil),        // 0: Node
-	(*Nodes)(nil),       // 1: Nodes
-	(*DecodeError)(nil), // 2: DecodeError
+	(DecodeErrorCode)(0), // 0: DecodeErrorCode
+	(*Node)(nil),         // 1: Node
+	(*Nodes)(nil),        // 2: Nodes
+	(*DecodeError)(nil),  // 3: DecodeError
 }
 var file_node_proto_depIdxs = []int32{
-	0, // 0: Nodes.nodes:type_name -> Node
-	0, // 1: DecodeError.node:type_name -> Node
-	2, // [2:2] is the sub-list for method output_type
-	2, // [2:2] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	1, // 0: Nodes.nodes:type_name -> Node
+	1, // 1: DecodeError.node:type_name -> Node
+	0, // 2: DecodeError.code:type_name -> DecodeErrorCode
+	3, // [3:3] is the sub-list for method output_type
+	3, // [3:3] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_node_proto_init() }
@@ -350,13 +430,14 @@ func file_node_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_node_proto_rawDesc,
-			NumEnums:      0,
+			NumEnums:      1,
 			NumMessages:   3,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
 		GoTypes:           file_node_proto_goTypes,
 		DependencyIndexes: file_node_proto_depIdxs,
+		EnumInfos:         file_node_proto_enumTypes,
 		MessageInfos:      file_node_proto_msgTypes,
 	}.Build()
 	File_node_proto = out.File
@@ -0,0 +1,712 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.31.0
+// 	protoc        v4.24.2
+// source: proof.proto
+
+package api
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type HashOp int32
+
+const (
+	HashOp_NO_HASH    HashOp = 0
+	HashOp_SHA256     HashOp = 1
+	HashOp_SHA512     HashOp = 2
+	HashOp_KECCAK256  HashOp = 3
+	HashOp_RIPEMD160  HashOp = 4
+	HashOp_BITCOIN    HashOp = 5
+	HashOp_SHA512_256 HashOp = 6
+)
+
+// Enum value maps for HashOp.
+var (
+	HashOp_name = map[int32]string{
+		0: "NO_HASH",
+		1: "SHA256",
+		2: "SHA512",
+		3: "KECCAK256",
+		4: "RIPEMD160",
+		5: "BITCOIN",
+		6: "SHA512_256",
+	}
+	HashOp_value = map[string]int32{
+		"NO_HASH":    0,
+		"SHA256":     1,
+		"SHA512":     2,
+		"KECCAK256":  3,
+		"RIPEMD160":  4,
+		"BITCOIN":    5,
+		"SHA512_256": 6,
+	}
+)
+
+func (x HashOp) Enum() *HashOp {
+	p := new(HashOp)
+	*p = x
+	return p
+}
+
+func (x HashOp) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (HashOp) Descriptor() protoreflect.EnumDescriptor {
+	return file_proof_proto_enumTypes[0].Descriptor()
+}
+
+func (HashOp) Type() protoreflect.EnumType {
+	return &file_proof_proto_enumTypes[0]
+}
+
+func (x HashOp) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use HashOp.Descriptor instead.
+func (HashOp) EnumDescriptor() ([]byte, []int) {
+	return file_proof_proto_rawDescGZIP(), []int{0}
+}
+
+type LengthOp int32
+
+const (
+	LengthOp_NO_PREFIX        LengthOp = 0
+	LengthOp_VAR_PROTO        LengthOp = 1
+	LengthOp_VAR_RLP          LengthOp = 2
+	LengthOp_FIXED32_BIG      LengthOp = 3
+	LengthOp_FIXED32_LITTLE   LengthOp = 4
+	LengthOp_FIXED64_BIG      LengthOp = 5
+	LengthOp_FIXED64_LITTLE   LengthOp = 6
+	LengthOp_REQUIRE_32_BYTES LengthOp = 7
+	LengthOp_REQUIRE_64_BYTES LengthOp = 8
+)
+
+// Enum value maps for LengthOp.
+var (
+	LengthOp_name = map[int32]string{
+		0: "NO_PREFIX",
+		1: "VAR_PROTO",
+		2: "VAR_RLP",
+		3: "FIXED32_BIG",
+		4: "FIXED32_LITTLE",
+		5: "FIXED64_BIG",
+		6: "FIXED64_LITTLE",
+		7: "REQUIRE_32_BYTES",
+		8: "REQUIRE_64_BYTES",
+	}
+	LengthOp_value = map[string]int32{
+		"NO_PREFIX":        0,
+		"VAR_PROTO":        1,
+		"VAR_RLP":          2,
+		"FIXED32_BIG":      3,
+		"FIXED32_LITTLE":   4,
+		"FIXED64_BIG":      5,
+		"FIXED64_LITTLE":   6,
+		"REQUIRE_32_BYTES": 7,
+		"REQUIRE_64_BYTES": 8,
+	}
+)
+
+func (x LengthOp) Enum() *LengthOp {
+	p := new(LengthOp)
+	*p = x
+	return p
+}
+
+func (x LengthOp) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (LengthOp) Descriptor() protoreflect.EnumDescriptor {
+	return file_proof_proto_enumTypes[1].Descriptor()
+}
+
+func (LengthOp) Type() protoreflect.EnumType {
+	return &file_proof_proto_enumTypes[1]
+}
+
+func (x LengthOp) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use LengthOp.Descriptor instead.
+func (LengthOp) EnumDescriptor() ([]byte, []int) {
+	return file_proof_proto_rawDescGZIP(), []int{1}
+}
+
+// ExistenceProof proves that key is set to value in the tree with the root hash obtained by applying leaf and then
+// each step of path, from the leaf up.
+type ExistenceProof struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key   []byte     `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value []byte     `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Leaf  *LeafOp    `protobuf:"bytes,3,opt,name=leaf,proto3" json:"leaf,omitempty"`
+	Path  []*InnerOp `protobuf:"bytes,4,rep,name=path,proto3" json:"path,omitempty"`
+}
+
+func (x *ExistenceProof) Reset() {
+	*x = ExistenceProof{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proof_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ExistenceProof) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExistenceProof) ProtoMessage() {}
+
+func (x *ExistenceProof) ProtoReflect() protoreflect.Message {
+	mi := &file_proof_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExistenceProof.ProtoReflect.Descriptor instead.
+func (*ExistenceProof) Descriptor() ([]byte, []int) {
+	return file_proof_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ExistenceProof) GetKey() []byte {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+func (x *ExistenceProof) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *ExistenceProof) GetLeaf() *LeafOp {
+	if x != nil {
+		return x.Leaf
+	}
+	return nil
+}
+
+func (x *ExistenceProof) GetPath() []*InnerOp {
+	if x != nil {
+		return x.Path
+	}
+	return nil
+}
+
+// NonExistenceProof proves that key is not set by proving that its neighbours, left and right, are adjacent.  Either
+// may be missing if key is before the first or after the last key in the tree.
+type NonExistenceProof struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key   []byte          `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Left  *ExistenceProof `protobuf:"bytes,2,opt,name=left,proto3" json:"left,omitempty"`
+	Right *ExistenceProof `protobuf:"bytes,3,opt,name=right,proto3" json:"right,omitempty"`
+}
+
+func (x *NonExistenceProof) Reset() {
+	*x = NonExistenceProof{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proof_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NonExistenceProof) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NonExistenceProof) ProtoMessage() {}
+
+func (x *NonExistenceProof) ProtoReflect() protoreflect.Message {
+	mi := &file_proof_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NonExistenceProof.ProtoReflect.Descriptor instead.
+func (*NonExistenceProof) Descriptor() ([]byte, []int) {
+	return file_proof_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *NonExistenceProof) GetKey() []byte {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+func (x *NonExistenceProof) GetLeft() *ExistenceProof {
+	if x != nil {
+		return x.Left
+	}
+	return nil
+}
+
+func (x *NonExistenceProof) GetRight() *ExistenceProof {
+	if x != nil {
+		return x.Right
+	}
+	return nil
+}
+
+type CommitmentProof struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Proof:
+	//	*CommitmentProof_Exist
+	//	*CommitmentProof_Nonexist
+	Proof isCommitmentProof_Proof `protobuf_oneof:"proof"`
+}
+
+func (x *CommitmentProof) Reset() {
+	*x = CommitmentProof{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proof_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CommitmentProof) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommitmentProof) ProtoMessage() {}
+
+func (x *CommitmentProof) ProtoReflect() protoreflect.Message {
+	mi := &file_proof_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommitmentProof.ProtoReflect.Descriptor instead.
+func (*CommitmentProof) Descriptor() ([]byte, []int) {
+	return file_proof_proto_rawDescGZIP(), []int{2}
+}
+
+func (m *CommitmentProof) GetProof() isCommitmentProof_Proof {
+	if m != nil {
+		return m.Proof
+	}
+	return nil
+}
+
+func (x *CommitmentProof) GetExist() *ExistenceProof {
+	if x, ok := x.GetProof().(*CommitmentProof_Exist); ok {
+		return x.Exist
+	}
+	return nil
+}
+
+func (x *CommitmentProof) GetNonexist() *NonExistenceProof {
+	if x, ok := x.GetProof().(*CommitmentProof_Nonexist); ok {
+		return x.Nonexist
+	}
+	return nil
+}
+
+type isCommitmentProof_Proof interface {
+	isCommitmentProof_Proof()
+}
+
+type CommitmentProof_Exist struct {
+	Exist *ExistenceProof `protobuf:"bytes,1,opt,name=exist,proto3,oneof"`
+}
+
+type CommitmentProof_Nonexist struct {
+	Nonexist *NonExistenceProof `protobuf:"bytes,2,opt,name=nonexist,proto3,oneof"`
+}
+
+func (*CommitmentProof_Exist) isCommitmentProof_Proof() {}
+
+func (*CommitmentProof_Nonexist) isCommitmentProof_Proof() {}
+
+// LeafOp hashes a key and value into a leaf hash: hash(prefix || length(prehash_key(key)) || prehash_key(key) ||
+// length(prehash_value(value)) || prehash_value(value)).
+type LeafOp struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Hash         HashOp   `protobuf:"varint,1,opt,name=hash,proto3,enum=HashOp" json:"hash,omitempty"`
+	PrehashKey   HashOp   `protobuf:"varint,2,opt,name=prehash_key,json=prehashKey,proto3,enum=HashOp" json:"prehash_key,omitempty"`
+	PrehashValue HashOp   `protobuf:"varint,3,opt,name=prehash_value,json=prehashValue,proto3,enum=HashOp" json:"prehash_value,omitempty"`
+	Length       LengthOp `protobuf:"varint,4,opt,name=length,proto3,enum=LengthOp" json:"length,omitempty"`
+	Prefix       []byte   `protobuf:"bytes,5,opt,name=prefix,proto3" json:"prefix,omitempty"`
+}
+
+func (x *LeafOp) Reset() {
+	*x = LeafOp{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proof_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LeafOp) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LeafOp) ProtoMessage() {}
+
+func (x *LeafOp) ProtoReflect() protoreflect.Message {
+	mi := &file_proof_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LeafOp.ProtoReflect.Descriptor instead.
+func (*LeafOp) Descriptor() ([]byte, []int) {
+	return file_proof_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *LeafOp) GetHash() HashOp {
+	if x != nil {
+		return x.Hash
+	}
+	return HashOp_NO_HASH
+}
+
+func (x *LeafOp) GetPrehashKey() HashOp {
+	if x != nil {
+		return x.PrehashKey
+	}
+	return HashOp_NO_HASH
+}
+
+func (x *LeafOp) GetPrehashValue() HashOp {
+	if x != nil {
+		return x.PrehashValue
+	}
+	return HashOp_NO_HASH
+}
+
+func (x *LeafOp) GetLength() LengthOp {
+	if x != nil {
+		return x.Length
+	}
+	return LengthOp_NO_PREFIX
+}
+
+func (x *LeafOp) GetPrefix() []byte {
+	if x != nil {
+		return x.Prefix
+	}
+	return nil
+}
+
+// InnerOp hashes a child hash into its parent's hash: hash(prefix || child || suffix).
+type InnerOp struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Hash   HashOp `protobuf:"varint,1,opt,name=hash,proto3,enum=HashOp" json:"hash,omitempty"`
+	Prefix []byte `protobuf:"bytes,2,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	Suffix []byte `protobuf:"bytes,3,opt,name=suffix,proto3" json:"suffix,omitempty"`
+}
+
+func (x *InnerOp) Reset() {
+	*x = InnerOp{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proof_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *InnerOp) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InnerOp) ProtoMessage() {}
+
+func (x *InnerOp) ProtoReflect() protoreflect.Message {
+	mi := &file_proof_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InnerOp.ProtoReflect.Descriptor instead.
+func (*InnerOp) Descriptor() ([]byte, []int) {
+	return file_proof_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *InnerOp) GetHash() HashOp {
+	if x != nil {
+		return x.Hash
+	}
+	return HashOp_NO_HASH
+}
+
+func (x *InnerOp) GetPrefix() []byte {
+	if x != nil {
+		return x.Prefix
+	}
+	return nil
+}
+
+func (x *InnerOp) GetSuffix() []byte {
+	if x != nil {
+		return x.Suffix
+	}
+	return nil
+}
+
+var File_proof_proto protoreflect.FileDescriptor
+
+var file_proof_proto_rawDesc = []byte{
+	0x0a, 0x0b, 0x70, 0x72, 0x6f, 0x6f, 0x66, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x73, 0x0a,
+	0x0e, 0x45, 0x78, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x63, 0x65, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x12,
+	0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x6b, 0x65,
+	0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x1b, 0x0a, 0x04, 0x6c, 0x65, 0x61, 0x66, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x07, 0x2e, 0x4c, 0x65, 0x61, 0x66, 0x4f, 0x70, 0x52, 0x04,
+	0x6c, 0x65, 0x61, 0x66, 0x12, 0x1c, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x04, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x08, 0x2e, 0x49, 0x6e, 0x6e, 0x65, 0x72, 0x4f, 0x70, 0x52, 0x04, 0x70, 0x61,
+	0x74, 0x68, 0x22, 0x71, 0x0a, 0x11, 0x4e, 0x6f, 0x6e, 0x45, 0x78, 0x69, 0x73, 0x74, 0x65, 0x6e,
+	0x63, 0x65, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x23, 0x0a, 0x04, 0x6c, 0x65, 0x66,
+	0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x45, 0x78, 0x69, 0x73, 0x74, 0x65,
+	0x6e, 0x63, 0x65, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x52, 0x04, 0x6c, 0x65, 0x66, 0x74, 0x12, 0x25,
+	0x0a, 0x05, 0x72, 0x69, 0x67, 0x68, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e,
+	0x45, 0x78, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x63, 0x65, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x52, 0x05,
+	0x72, 0x69, 0x67, 0x68, 0x74, 0x22, 0x75, 0x0a, 0x0f, 0x43, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x6d,
+	0x65, 0x6e, 0x74, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x12, 0x27, 0x0a, 0x05, 0x65, 0x78, 0x69, 0x73,
+	0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x45, 0x78, 0x69, 0x73, 0x74, 0x65,
+	0x6e, 0x63, 0x65, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x48, 0x00, 0x52, 0x05, 0x65, 0x78, 0x69, 0x73,
+	0x74, 0x12, 0x30, 0x0a, 0x08, 0x6e, 0x6f, 0x6e, 0x65, 0x78, 0x69, 0x73, 0x74, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x4e, 0x6f, 0x6e, 0x45, 0x78, 0x69, 0x73, 0x74, 0x65, 0x6e,
+	0x63, 0x65, 0x50, 0x72, 0x6f, 0x6f, 0x66, 0x48, 0x00, 0x52, 0x08, 0x6e, 0x6f, 0x6e, 0x65, 0x78,
+	0x69, 0x73, 0x74, 0x42, 0x07, 0x0a, 0x05, 0x70, 0x72, 0x6f, 0x6f, 0x66, 0x22, 0xb8, 0x01, 0x0a,
+	0x06, 0x4c, 0x65, 0x61, 0x66, 0x4f, 0x70, 0x12, 0x1b, 0x0a, 0x04, 0x68, 0x61, 0x73, 0x68, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x07, 0x2e, 0x48, 0x61, 0x73, 0x68, 0x4f, 0x70, 0x52, 0x04,
+	0x68, 0x61, 0x73, 0x68, 0x12, 0x28, 0x0a, 0x0b, 0x70, 0x72, 0x65, 0x68, 0x61, 0x73, 0x68, 0x5f,
+	0x6b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x07, 0x2e, 0x48, 0x61, 0x73, 0x68,
+	0x4f, 0x70, 0x52, 0x0a, 0x70, 0x72, 0x65, 0x68, 0x61, 0x73, 0x68, 0x4b, 0x65, 0x79, 0x12, 0x2c,
+	0x0a, 0x0d, 0x70, 0x72, 0x65, 0x68, 0x61, 0x73, 0x68, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x07, 0x2e, 0x48, 0x61, 0x73, 0x68, 0x4f, 0x70, 0x52, 0x0c,
+	0x70, 0x72, 0x65, 0x68, 0x61, 0x73, 0x68, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x21, 0x0a, 0x06,
+	0x6c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x09, 0x2e, 0x4c,
+	0x65, 0x6e, 0x67, 0x74, 0x68, 0x4f, 0x70, 0x52, 0x06, 0x6c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x12,
+	0x16, 0x0a, 0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x22, 0x56, 0x0a, 0x07, 0x49, 0x6e, 0x6e, 0x65, 0x72,
+	0x4f, 0x70, 0x12, 0x1b, 0x0a, 0x04, 0x68, 0x61, 0x73, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x07, 0x2e, 0x48, 0x61, 0x73, 0x68, 0x4f, 0x70, 0x52, 0x04, 0x68, 0x61, 0x73, 0x68, 0x12,
+	0x16, 0x0a, 0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x75, 0x66, 0x66, 0x69,
+	0x78, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x73, 0x75, 0x66, 0x66, 0x69, 0x78, 0x2a,
+	0x68, 0x0a, 0x06, 0x48, 0x61, 0x73, 0x68, 0x4f, 0x70, 0x12, 0x0b, 0x0a, 0x07, 0x4e, 0x4f, 0x5f,
+	0x48, 0x41, 0x53, 0x48, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x53, 0x48, 0x41, 0x32, 0x35, 0x36,
+	0x10, 0x01, 0x12, 0x0a, 0x0a, 0x06, 0x53, 0x48, 0x41, 0x35, 0x31, 0x32, 0x10, 0x02, 0x12, 0x0d,
+	0x0a, 0x09, 0x4b, 0x45, 0x43, 0x43, 0x41, 0x4b, 0x32, 0x35, 0x36, 0x10, 0x03, 0x12, 0x0d, 0x0a,
+	0x09, 0x52, 0x49, 0x50, 0x45, 0x4d, 0x44, 0x31, 0x36, 0x30, 0x10, 0x04, 0x12, 0x0b, 0x0a, 0x07,
+	0x42, 0x49, 0x54, 0x43, 0x4f, 0x49, 0x4e, 0x10, 0x05, 0x12, 0x0e, 0x0a, 0x0a, 0x53, 0x48, 0x41,
+	0x35, 0x31, 0x32, 0x5f, 0x32, 0x35, 0x36, 0x10, 0x06, 0x2a, 0xab, 0x01, 0x0a, 0x08, 0x4c, 0x65,
+	0x6e, 0x67, 0x74, 0x68, 0x4f, 0x70, 0x12, 0x0d, 0x0a, 0x09, 0x4e, 0x4f, 0x5f, 0x50, 0x52, 0x45,
+	0x46, 0x49, 0x58, 0x10, 0x00, 0x12, 0x0d, 0x0a, 0x09, 0x56, 0x41, 0x52, 0x5f, 0x50, 0x52, 0x4f,
+	0x54, 0x4f, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x56, 0x41, 0x52, 0x5f, 0x52, 0x4c, 0x50, 0x10,
+	0x02, 0x12, 0x0f, 0x0a, 0x0b, 0x46, 0x49, 0x58, 0x45, 0x44, 0x33, 0x32, 0x5f, 0x42, 0x49, 0x47,
+	0x10, 0x03, 0x12, 0x12, 0x0a, 0x0e, 0x46, 0x49, 0x58, 0x45, 0x44, 0x33, 0x32, 0x5f, 0x4c, 0x49,
+	0x54, 0x54, 0x4c, 0x45, 0x10, 0x04, 0x12, 0x0f, 0x0a, 0x0b, 0x46, 0x49, 0x58, 0x45, 0x44, 0x36,
+	0x34, 0x5f, 0x42, 0x49, 0x47, 0x10, 0x05, 0x12, 0x12, 0x0a, 0x0e, 0x46, 0x49, 0x58, 0x45, 0x44,
+	0x36, 0x34, 0x5f, 0x4c, 0x49, 0x54, 0x54, 0x4c, 0x45, 0x10, 0x06, 0x12, 0x14, 0x0a, 0x10, 0x52,
+	0x45, 0x51, 0x55, 0x49, 0x52, 0x45, 0x5f, 0x33, 0x32, 0x5f, 0x42, 0x59, 0x54, 0x45, 0x53, 0x10,
+	0x07, 0x12, 0x14, 0x0a, 0x10, 0x52, 0x45, 0x51, 0x55, 0x49, 0x52, 0x45, 0x5f, 0x36, 0x34, 0x5f,
+	0x42, 0x59, 0x54, 0x45, 0x53, 0x10, 0x08, 0x42, 0x26, 0x5a, 0x24, 0x67, 0x69, 0x74, 0x68, 0x75,
+	0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6b, 0x6f, 0x63, 0x75, 0x62, 0x69, 0x6e, 0x73, 0x6b, 0x69,
+	0x2f, 0x63, 0x6f, 0x73, 0x74, 0x6f, 0x72, 0x2d, 0x61, 0x70, 0x69, 0x2f, 0x61, 0x70, 0x69, 0x62,
+	0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_proof_proto_rawDescOnce sync.Once
+	file_proof_proto_rawDescData = file_proof_proto_rawDesc
+)
+
+func file_proof_proto_rawDescGZIP() []byte {
+	file_proof_proto_rawDescOnce.Do(func() {
+		file_proof_proto_rawDescData = protoimpl.X.CompressGZIP(file_proof_proto_rawDescData)
+	})
+	return file_proof_proto_rawDescData
+}
+
+var file_proof_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_proof_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_proof_proto_goTypes = []interface{}{
+	(HashOp)(0),               // 0: HashOp
+	(LengthOp)(0),             // 1: LengthOp
+	(*ExistenceProof)(nil),    // 2: ExistenceProof
+	(*NonExistenceProof)(nil), // 3: NonExistenceProof
+	(*CommitmentProof)(nil),   // 4: CommitmentProof
+	(*LeafOp)(nil),            // 5: LeafOp
+	(*InnerOp)(nil),           // 6: InnerOp
+}
+var file_proof_proto_depIdxs = []int32{
+	5,  // 0: ExistenceProof.leaf:type_name -> LeafOp
+	6,  // 1: ExistenceProof.path:type_name -> InnerOp
+	2,  // 2: NonExistenceProof.left:type_name -> ExistenceProof
+	2,  // 3: NonExistenceProof.right:type_name -> ExistenceProof
+	2,  // 4: CommitmentProof.exist:type_name -> ExistenceProof
+	3,  // 5: CommitmentProof.nonexist:type_name -> NonExistenceProof
+	0,  // 6: LeafOp.hash:type_name -> HashOp
+	0,  // 7: LeafOp.prehash_key:type_name -> HashOp
+	0,  // 8: LeafOp.prehash_value:type_name -> HashOp
+	1,  // 9: LeafOp.length:type_name -> LengthOp
+	0,  // 10: InnerOp.hash:type_name -> HashOp
+	11, // [11:11] is the sub-list for method output_type
+	11, // [11:11] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
+}
+
+func init() { file_proof_proto_init() }
+func file_proof_proto_init() {
+	if File_proof_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_proof_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ExistenceProof); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proof_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NonExistenceProof); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proof_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CommitmentProof); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proof_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LeafOp); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proof_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*InnerOp); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_proof_proto_msgTypes[2].OneofWrappers = []interface{}{
+		(*CommitmentProof_Exist)(nil),
+		(*CommitmentProof_Nonexist)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_proof_proto_rawDesc,
+			NumEnums:      2,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_proof_proto_goTypes,
+		DependencyIndexes: file_proof_proto_depIdxs,
+		EnumInfos:         file_proof_proto_enumTypes,
+		MessageInfos:      file_proof_proto_msgTypes,
+	}.Build()
+	File_proof_proto = out.File
+	file_proof_proto_rawDesc = nil
+	file_proof_proto_goTypes = nil
+	file_proof_proto_depIdxs = nil
+}
@@ -18,4 +18,34 @@ func IsDecodeError(err error) bool {
 type Changeset struct {
 	Version int64
 	Nodes   []*Node
+	// Partial is set when the writes of Version did not fit in one changeset and more changesets with the same
+	// Version follow.
+	Partial bool
+}
+
+// Iterator returns an iterator over the changeset's nodes.
+func (c *Changeset) Iterator() NodeIterator {
+	return &sliceNodeIterator{nodes: c.Nodes}
+}
+
+// sliceNodeIterator iterates over a slice of nodes.
+type sliceNodeIterator struct {
+	nodes []*Node
+	idx   int
+}
+
+func (s *sliceNodeIterator) Next() error {
+	s.idx++
+	return nil
+}
+
+func (s *sliceNodeIterator) Valid() bool {
+	return s.idx < len(s.nodes)
+}
+
+func (s *sliceNodeIterator) GetNode() *Node {
+	if !s.Valid() {
+		return nil
+	}
+	return s.nodes[s.idx]
 }